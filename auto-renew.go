@@ -0,0 +1,183 @@
+package vaulttoken
+
+import (
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+// StartAutoRenew keeps the login token fresh in the background, so that a
+// long-running service doesn't need to poll GetApiInterface. When the token
+// comes within renewBefore of its expiration, it is renewed; if renewal
+// fails or can't extend the token (it reached its max TTL), a fresh login is
+// made. Renewals and logins are published as token events.
+//
+// The goroutine runs until stop is called, the lane's context ends, or the
+// connection is shut down. Starting auto-renew while it is already running
+// does nothing but return the running renewer's stop function; its
+// renewBefore stays in effect. Any other background renewer, such as the
+// lifetime watcher, is stopped. A static token can't be renewed by login,
+// so for a static token connection, nothing is started.
+func (vcc *VaultClientConnection) StartAutoRenew(l lane.Lane, renewBefore time.Duration) (stop func()) {
+	if vcc.auth == nil {
+		l.Warnf("vault client: auto-renew requires a login-based connection")
+		return func() {}
+	}
+
+	// serialize starts, so concurrent calls can't both start a renewer
+	vcc.renewStartMu.Lock()
+	defer vcc.renewStartMu.Unlock()
+
+	vcc.renewMu.Lock()
+	if vcc.autoRenewRunning() {
+		stop = vcc.stopRenew
+		vcc.renewMu.Unlock()
+		l.Debugf("vault client: auto-renew is already running")
+		return
+	}
+	vcc.renewMu.Unlock()
+
+	vcc.stopRenewer()
+
+	stopCh := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		vcc.autoRenew(l, renewBefore, stopCh)
+	}()
+
+	stop = vcc.setRenewer(stopCh, finished, true)
+	return
+}
+
+// NextRenewalTime provides when auto-renew will next renew the token: the
+// current token's expiration less the renewBefore given to StartAutoRenew,
+// or less half of the token's remaining life if that is shorter. It is zero
+// if auto-renew isn't running, there is no login token yet, or the token
+// never expires.
+func (vcc *VaultClientConnection) NextRenewalTime() time.Time {
+	vcc.renewMu.Lock()
+	defer vcc.renewMu.Unlock()
+	if !vcc.autoRenewRunning() {
+		return time.Time{}
+	}
+	return vcc.nextRenewal
+}
+
+// kMinRenewInterval is the least time auto-renew waits before a renewal or
+// login, so a token with a very short TTL can't make it spin
+const kMinRenewInterval = time.Second
+
+// kNeverExpiresRecheck is how often auto-renew checks whether a token without
+// a TTL has been replaced by a login
+const kNeverExpiresRecheck = time.Minute
+
+// renewalLead is how long before expiration a token with remaining life is
+// renewed: renewBefore, but no more than half of the remaining life, so a
+// renewBefore as long as the TTL doesn't make each renewal due at once
+func renewalLead(remaining, renewBefore time.Duration) time.Duration {
+	if renewBefore > remaining/2 {
+		return remaining / 2
+	}
+	return renewBefore
+}
+
+// setNextRenewal records the schedule reported by NextRenewalTime
+func (vcc *VaultClientConnection) setNextRenewal(at time.Time) {
+	vcc.renewMu.Lock()
+	vcc.nextRenewal = at
+	vcc.renewMu.Unlock()
+}
+
+// autoRenew renews or replaces the token each time it nears expiration,
+// until the caller asks to stop
+func (vcc *VaultClientConnection) autoRenew(l lane.Lane, renewBefore time.Duration, stopCh chan struct{}) {
+	for {
+		provider, err := vcc.liveProvider()
+		if err != nil {
+			if vcc.reloginUntilStopped(l, stopCh) == nil {
+				return
+			}
+			continue
+		}
+
+		// a token without a TTL has nothing to renew, but a later login may
+		// replace it with one that does
+		var lead, wait time.Duration
+		expiration := provider.expiresAt()
+		renewable := !expiration.Equal(neverExpires)
+		if renewable {
+			now := vcc.opts.clock.Now()
+			remaining := expiration.Sub(now)
+			lead = renewalLead(remaining, renewBefore)
+			wait = max(remaining-lead, kMinRenewInterval)
+			vcc.setNextRenewal(now.Add(wait))
+		} else {
+			wait = kNeverExpiresRecheck
+			vcc.setNextRenewal(time.Time{})
+		}
+
+		due, stopTimer := vcc.opts.clock.NewTimer(wait)
+		select {
+		case <-stopCh:
+			stopTimer()
+			return
+		case <-l.Done():
+			stopTimer()
+			return
+		case <-due:
+		}
+
+		if !renewable {
+			continue
+		}
+		if vcc.renewProvider(l, provider, lead) {
+			continue
+		}
+		if vcc.reloginUntilStopped(l, stopCh) == nil {
+			return
+		}
+	}
+}
+
+// renewProvider renews the token, returning false if a fresh login is needed
+// instead; that includes a renewal that leaves the token due for renewal
+// again, which happens as it nears its max TTL
+func (vcc *VaultClientConnection) renewProvider(l lane.Lane, provider VaultToken, lead time.Duration) bool {
+	if expired, err := provider.isExpired(l); err != nil || expired {
+		l.Infof("vault client: token expired before it could be renewed")
+		if err == nil {
+			err = ErrTokenExpired
+		}
+		vcc.publishTokenEvent(TokenRenewFailed, 0, err)
+		return false
+	}
+
+	vcc.loginMu.Lock()
+	var ttlSeconds int
+	if vcc.lastLogin != nil && vcc.lastLogin.Auth != nil {
+		ttlSeconds = vcc.lastLogin.Auth.LeaseDuration
+	}
+	vcc.loginMu.Unlock()
+
+	err := vcc.retryOp(l, 3, func() error {
+		return provider.refresh(l, ttlSeconds)
+	})
+	if err != nil {
+		vcc.stats.recordError(err)
+		vcc.publishTokenEvent(TokenRenewFailed, 0, err)
+		return false
+	}
+
+	ttl := provider.expiresAt().Sub(vcc.opts.clock.Now())
+	if ttl <= lead {
+		l.Infof("vault client: token reached its max ttl, logging in again")
+		vcc.publishTokenEvent(TokenRenewFailed, 0, nil)
+		return false
+	}
+
+	l.Tracef("vault client: token renewed by auto-renew, ttl %s", ttl)
+	vcc.stats.refreshes.Add(1)
+	vcc.publishTokenEvent(TokenRenewed, ttl, nil)
+	return true
+}
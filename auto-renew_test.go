@@ -0,0 +1,302 @@
+package vaulttoken
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// fakeClock is a clock that only moves when told to
+	fakeClock struct {
+		mu     sync.Mutex
+		now    time.Time
+		timers []*fakeTimer
+	}
+
+	fakeTimer struct {
+		at   time.Time
+		c    chan time.Time
+		done bool
+	}
+)
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2030, time.January, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) NewTimer(d time.Duration) (c <-chan time.Time, stop func() bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	ft := &fakeTimer{at: fc.now.Add(d), c: make(chan time.Time, 1)}
+	fc.timers = append(fc.timers, ft)
+	return ft.c, func() bool {
+		fc.mu.Lock()
+		defer fc.mu.Unlock()
+		wasPending := !ft.done
+		ft.done = true
+		return wasPending
+	}
+}
+
+// advance moves the clock to at, firing the timers that come due
+func (fc *fakeClock) advance(at time.Time) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = at
+	for _, ft := range fc.timers {
+		if !ft.done && !ft.at.After(at) {
+			ft.done = true
+			ft.c <- at
+		}
+	}
+}
+
+// waitForTimer waits for a timer to be pending
+func (fc *fakeClock) waitForTimer(t testing.TB) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		fc.mu.Lock()
+		for _, ft := range fc.timers {
+			if !ft.done {
+				fc.mu.Unlock()
+				return
+			}
+		}
+		fc.mu.Unlock()
+		if time.Now().After(deadline) {
+			t.Fatal("no timer was started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// waitForGoroutines fails the test if the goroutine count doesn't settle back
+// to baseline
+func waitForGoroutines(t testing.TB, baseline int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		n := runtime.NumGoroutine()
+		if n <= baseline {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines leaked:\n%s", n-baseline, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitForNextRenewal waits for auto-renew to schedule its next renewal
+func waitForNextRenewal(t testing.TB, vcc *VaultClientConnection) time.Time {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if next := vcc.NextRenewalTime(); !next.IsZero() {
+			return next
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("auto-renew didn't schedule a renewal")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStartAutoRenewIdempotent(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	baseline := runtime.NumGoroutine()
+
+	stop := vcc.StartAutoRenew(l, time.Minute)
+	if !vcc.IsAutoRenewRunning() {
+		t.Fatal("auto-renew isn't running")
+	}
+	running := runtime.NumGoroutine()
+
+	// starting again leaves the one renewer in place
+	for range 5 {
+		vcc.StartAutoRenew(l, time.Second)
+	}
+	if n := runtime.NumGoroutine(); n > running {
+		t.Errorf("repeated starts added %d goroutines", n-running)
+	}
+	if next := waitForNextRenewal(t, vcc); time.Until(next) > time.Hour-time.Minute {
+		t.Errorf("the first renewBefore wasn't kept, next renewal at %v", next)
+	}
+
+	stop()
+	if vcc.IsAutoRenewRunning() {
+		t.Error("auto-renew still running after stop")
+	}
+	waitForGoroutines(t, baseline)
+
+	// stopping twice is harmless
+	stop()
+}
+
+func TestStartAutoRenewStopsWithLane(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	baseline := runtime.NumGoroutine()
+
+	rl, cancel := l.DeriveWithCancel()
+	vcc.StartAutoRenew(rl, time.Minute)
+	cancel()
+
+	waitForGoroutines(t, baseline)
+	if vcc.IsAutoRenewRunning() {
+		t.Error("auto-renew still running after the lane was cancelled")
+	}
+}
+
+func TestStartAutoRenewLongRenewBefore(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.ttl = 60
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// renewBefore beyond the 60s TTL is held to half of the token's life,
+	// rather than making every renewal due at once
+	stop := vcc.StartAutoRenew(l, 5*time.Minute)
+	defer stop()
+
+	next := waitForNextRenewal(t, vcc)
+	if wait := time.Until(next); wait < 25*time.Second || wait > 30*time.Second {
+		t.Errorf("expected the renewal in about 30s, got %v", wait)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+	if n := mv.renewals.Load(); n != 0 {
+		t.Errorf("expected no renewals yet, got %d", n)
+	}
+}
+
+func TestRenewalLead(t *testing.T) {
+	cases := []struct {
+		remaining, renewBefore, lead time.Duration
+	}{
+		{time.Hour, time.Minute, time.Minute},
+		{time.Minute, 5 * time.Minute, 30 * time.Second},
+		{time.Minute, time.Minute, 30 * time.Second},
+		{0, time.Minute, 0},
+	}
+	for _, c := range cases {
+		if lead := renewalLead(c.remaining, c.renewBefore); lead != c.lead {
+			t.Errorf("renewalLead(%v, %v) = %v, expected %v", c.remaining, c.renewBefore, lead, c.lead)
+		}
+	}
+}
+
+func TestNextRenewalTimeMovesForward(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if next := vcc.NextRenewalTime(); !next.IsZero() {
+		t.Errorf("expected no renewal time before auto-renew starts, got %v", next)
+	}
+
+	stop := vcc.StartAutoRenew(l, 10*time.Minute)
+	defer stop()
+
+	// the 1h token is renewed 10m before it expires, and each renewal
+	// extends it by another hour
+	start := fc.Now()
+	next := waitForNextRenewal(t, vcc)
+	if expected := start.Add(50 * time.Minute); !next.Equal(expected) {
+		t.Fatalf("expected the first renewal at %v, got %v", expected, next)
+	}
+
+	for i := 1; i <= 3; i++ {
+		fc.waitForTimer(t)
+		fc.advance(next)
+
+		prior := next
+		deadline := time.Now().Add(2 * time.Second)
+		for next.Equal(prior) {
+			if time.Now().After(deadline) {
+				t.Fatalf("renewal %d didn't reschedule", i)
+			}
+			time.Sleep(5 * time.Millisecond)
+			next = vcc.NextRenewalTime()
+		}
+
+		if expected := prior.Add(50 * time.Minute); !next.Equal(expected) {
+			t.Errorf("after renewal %d, expected the next at %v, got %v", i, expected, next)
+		}
+		if n := mv.renewals.Load(); n != int32(i) {
+			t.Errorf("expected %d renewals, got %d", i, n)
+		}
+	}
+
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestAutoRenewZeroTTL(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.ttl = 0
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	stop := vcc.StartAutoRenew(l, 10*time.Minute)
+	defer stop()
+
+	// auto-renew only looks in now and then; a token without a TTL is
+	// never renewed or replaced
+	for i := 0; i < 5; i++ {
+		fc.waitForTimer(t)
+		if next := vcc.NextRenewalTime(); !next.IsZero() {
+			t.Fatalf("expected no renewal time, got %v", next)
+		}
+		fc.advance(fc.Now().Add(24 * time.Hour))
+	}
+	fc.waitForTimer(t)
+
+	if n := mv.renewals.Load(); n != 0 {
+		t.Errorf("expected no renewals, got %d", n)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
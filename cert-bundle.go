@@ -0,0 +1,59 @@
+package vaulttoken
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/jimsnab/go-lane"
+)
+
+// ReadCertificateBundle reads a KV v2 secret holding a PEM "certificate" and
+// "private_key" (the field names used by the PKI engine) and assembles them
+// into a tls.Certificate. An optional "ca_chain" (string or list of strings)
+// or "issuing_ca" is appended to the certificate chain.
+func (vcc *VaultClientConnection) ReadCertificateBundle(l lane.Lane, mount, path string) (cert *tls.Certificate, err error) {
+	var data map[string]any
+	if data, err = vcc.ReadKVv2(l, mount, path); err != nil {
+		return
+	}
+
+	certPem, _ := data["certificate"].(string)
+	keyPem, _ := data["private_key"].(string)
+	if certPem == "" || keyPem == "" {
+		err = fmt.Errorf("secret %s/%s does not contain certificate and private_key", mount, path)
+		l.Errorf("vault client: %v", err)
+		return
+	}
+
+	chain := []string{certPem}
+	switch ca := data["ca_chain"].(type) {
+	case string:
+		chain = append(chain, ca)
+	case []any:
+		for _, item := range ca {
+			if pem, ok := item.(string); ok {
+				chain = append(chain, pem)
+			}
+		}
+	default:
+		if issuer, ok := data["issuing_ca"].(string); ok {
+			chain = append(chain, issuer)
+		}
+	}
+
+	var pair tls.Certificate
+	if pair, err = tls.X509KeyPair([]byte(strings.Join(chain, "\n")), []byte(keyPem)); err != nil {
+		l.Errorf("vault client: invalid certificate bundle in %s/%s: %v", mount, path, err)
+		return
+	}
+
+	if pair.Leaf, err = x509.ParseCertificate(pair.Certificate[0]); err != nil {
+		l.Errorf("vault client: can't parse leaf certificate in %s/%s: %v", mount, path, err)
+		return
+	}
+
+	cert = &pair
+	return
+}
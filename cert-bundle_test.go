@@ -0,0 +1,99 @@
+package vaulttoken
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+// newTestCert makes a certificate for cn, signed by parent's key, or
+// self-signed if parent is nil; it returns the cert and key as PEM
+func newTestCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (cert *x509.Certificate, key *ecdsa.PrivateKey, certPem, keyPem string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPem = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPem = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	return
+}
+
+func TestReadCertificateBundle(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	ca, caKey, caPem, _ := newTestCert(t, "test-ca", nil, nil)
+	_, _, leafPem, leafKeyPem := newTestCert(t, "service.example.com", ca, caKey)
+
+	secret, _ := json.Marshal(map[string]any{
+		"certificate": leafPem,
+		"private_key": leafKeyPem,
+		"ca_chain":    []string{caPem},
+	})
+	mv.putSecret("secret", "tls", string(secret))
+	vcc := newStaticClient(t, l, mv)
+
+	cert, err := vcc.ReadCertificateBundle(l, "secret", "tls")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if cert.Leaf.Subject.CommonName != "service.example.com" {
+		t.Errorf("unexpected leaf %s", cert.Leaf.Subject.CommonName)
+	}
+	if len(cert.Certificate) != 2 {
+		t.Fatalf("expected the leaf and ca in the chain, got %d certs", len(cert.Certificate))
+	}
+	if issuer, _ := x509.ParseCertificate(cert.Certificate[1]); issuer.Subject.CommonName != "test-ca" {
+		t.Errorf("unexpected issuer %s", issuer.Subject.CommonName)
+	}
+}
+
+func TestReadCertificateBundleMissingKey(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	_, _, certPem, _ := newTestCert(t, "service.example.com", nil, nil)
+	secret, _ := json.Marshal(map[string]any{"certificate": certPem})
+	mv.putSecret("secret", "tls", string(secret))
+	vcc := newStaticClient(t, l, mv)
+
+	if _, err := vcc.ReadCertificateBundle(l, "secret", "tls"); err == nil {
+		t.Error("expected a secret without private_key to fail")
+	}
+}
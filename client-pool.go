@@ -0,0 +1,158 @@
+package vaulttoken
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// ClientPool holds a fixed set of Vault clients, each with its own
+	// token kept renewed in the background, for services that need many
+	// concurrent authenticated clients with independent tokens.
+	ClientPool struct {
+		idle  chan *VaultClientConnection
+		mu    sync.Mutex
+		conns map[*vaultapi.Client]*VaultClientConnection
+		out   map[*VaultClientConnection]bool
+		stops []func()
+	}
+)
+
+var errPoolClosed = errors.New("the client pool is closed")
+
+// Clone makes a new connection with the same server, options and auth as
+// vcc, but its own token. A clone of a static token connection shares the
+// static token.
+func (vcc *VaultClientConnection) Clone(l lane.Lane) (clone *VaultClientConnection, err error) {
+	if clone, err = newVaultConnection(l, vcc.vc.Address(), vcc.opts); err != nil {
+		return
+	}
+
+	if vcc.auth == nil {
+		token := vcc.vc.Token()
+		clone.vc.SetToken(token)
+		clone.setProvider(newStaticToken(token, clone.vc, clone.transport))
+		return
+	}
+
+	clone.auth = vcc.auth
+	clone.authCfg = vcc.authCfg
+	clone.role = vcc.role
+	return
+}
+
+// NewClientPool makes size clones of vcc, logs each in, and keeps each token
+// renewed with StartAutoRenew until the pool is closed or the lane's context
+// ends.
+func NewClientPool(l lane.Lane, vcc *VaultClientConnection, size int, renewBefore time.Duration) (pool *ClientPool, err error) {
+	if size <= 0 {
+		err = errors.New("the client pool size must be positive")
+		return
+	}
+
+	p := &ClientPool{
+		idle:  make(chan *VaultClientConnection, size),
+		conns: make(map[*vaultapi.Client]*VaultClientConnection, size),
+		out:   make(map[*VaultClientConnection]bool, size),
+	}
+
+	for range size {
+		var clone *VaultClientConnection
+		if clone, err = vcc.Clone(l); err != nil {
+			p.Close(l)
+			return
+		}
+
+		var vc *vaultapi.Client
+		if vc, err = clone.GetApiInterface(l); err != nil {
+			l.Errorf("vault client: can't authenticate pooled client: %v", err)
+			clone.Close(l)
+			p.Close(l)
+			return
+		}
+
+		p.conns[vc] = clone
+		p.stops = append(p.stops, clone.StartAutoRenew(l, renewBefore))
+		p.idle <- clone
+	}
+
+	pool = p
+	return
+}
+
+// Get takes an authenticated client from the pool, waiting for one to be
+// returned if all are in use. Give it back with Put.
+func (p *ClientPool) Get(l lane.Lane) (vc *vaultapi.Client, err error) {
+	select {
+	case vcc, ok := <-p.idle:
+		if !ok {
+			err = errPoolClosed
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.conns == nil {
+			// closed while this client was handed over
+			err = errPoolClosed
+			return
+		}
+		p.out[vcc] = true
+		vc = vcc.vc
+	case <-l.Done():
+		err = l.Err()
+	}
+	return
+}
+
+// Put returns a client taken by Get to the pool. A client that isn't
+// checked out, e.g., one already returned, is ignored.
+func (p *ClientPool) Put(vc *vaultapi.Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	vcc, found := p.conns[vc]
+	if !found || !p.out[vcc] {
+		return
+	}
+	delete(p.out, vcc)
+
+	// never blocks, since only checked out clients are returned
+	select {
+	case p.idle <- vcc:
+	default:
+	}
+}
+
+// Close stops the background renewals and closes each pooled connection,
+// revoking its token. Clients still in use must not be used afterward.
+func (p *ClientPool) Close(l lane.Lane) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conns == nil {
+		return
+	}
+
+	for _, stop := range p.stops {
+		stop()
+	}
+
+	var errs []error
+	for _, vcc := range p.conns {
+		errs = append(errs, vcc.Close(l))
+	}
+
+	p.conns = nil
+	p.out = nil
+	p.stops = nil
+	close(p.idle)
+	for range p.idle {
+		// discard the idle connections so Get reports the pool is closed
+	}
+	err = errors.Join(errs...)
+	return
+}
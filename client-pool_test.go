@@ -0,0 +1,82 @@
+package vaulttoken
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+func TestClientPool(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	pool, err := NewClientPool(l, vcc, 3, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("can't make the pool: %v", err)
+	}
+	defer pool.Close(l)
+
+	// each pooled client logs in on its own
+	if n := mv.logins.Load(); n != 3 {
+		t.Errorf("expected 3 logins, got %d", n)
+	}
+
+	// and is handed out with its own token
+	tokens := map[string]bool{}
+	var clients []*vaultapi.Client
+	for range 3 {
+		vc, err := pool.Get(l)
+		if err != nil {
+			t.Fatalf("can't get a pooled client: %v", err)
+		}
+		if _, err = vc.Auth().Token().LookupSelfWithContext(l); err != nil {
+			t.Errorf("the pooled client isn't authenticated: %v", err)
+		}
+		tokens[vc.Token()] = true
+		clients = append(clients, vc)
+	}
+	if len(tokens) != 3 {
+		t.Errorf("expected 3 distinct tokens, got %v", tokens)
+	}
+
+	// with all in use, the next Get waits for a Put
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err = pool.Get(lane.NewTestingLane(ctx)); err == nil {
+		t.Error("expected Get to wait on an exhausted pool")
+	}
+	pool.Put(clients[0])
+	if vc, err := pool.Get(l); err != nil || vc != clients[0] {
+		t.Errorf("expected the returned client back, got %v, %v", vc, err)
+	}
+
+	// each token is kept renewed
+	var next time.Time
+	for _, clone := range pool.conns {
+		next = waitForNextRenewal(t, clone)
+	}
+	fc.advance(next)
+	deadline := time.Now().Add(2 * time.Second)
+	for mv.renewals.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 renewals, got %d", mv.renewals.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// and revoked when the pool closes
+	if err = pool.Close(l); err != nil {
+		t.Errorf("close failed: %v", err)
+	}
+	if n := mv.revocations.Load(); n != 3 {
+		t.Errorf("expected 3 revocations, got %d", n)
+	}
+	if _, err = pool.Get(l); err == nil {
+		t.Error("expected Get on a closed pool to fail")
+	}
+}
@@ -0,0 +1,33 @@
+package vaulttoken
+
+import (
+	"time"
+)
+
+type (
+	// clock tells the time for token expirations and the auto-renew
+	// schedule, so tests can drive renewals without waiting for them
+	clock interface {
+		Now() time.Time
+		NewTimer(d time.Duration) (c <-chan time.Time, stop func() bool)
+	}
+
+	systemClock struct {
+	}
+)
+
+// withClock replaces the connection's time source
+func withClock(c clock) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.clock = c
+	}
+}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) (c <-chan time.Time, stop func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
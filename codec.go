@@ -0,0 +1,105 @@
+package vaulttoken
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// JSONCodec decodes the Vault responses parsed by the connection's helper
+	// methods. The default is encoding/json; a faster compatible library
+	// (e.g., jsoniter's ConfigCompatibleWithStandardLibrary) can be plugged in
+	// with WithJSONCodec for services reading large secrets at high rates.
+	JSONCodec interface {
+		Marshal(v any) ([]byte, error)
+		Unmarshal(data []byte, v any) error
+	}
+
+	stdJSONCodec struct {
+	}
+
+	// kvv2Envelope is the response shape of a KV v2 data read
+	kvv2Envelope struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+)
+
+// WithJSONCodec replaces the JSON codec used by the helper methods. A nil
+// codec is rejected, leaving the default in place. Like vaultapi, the
+// default decodes numbers as json.Number; a replacement should too, or
+// callers' type assertions on secret values may break.
+func WithJSONCodec(codec JSONCodec) VaultOption {
+	return func(opts *vaultOptions) {
+		if codec == nil {
+			codec = stdJSONCodec{}
+		}
+		opts.codec = codec
+	}
+}
+
+func (c stdJSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes numbers as json.Number, as vaultapi does, so large
+// integers keep their precision
+func (c stdJSONCodec) Unmarshal(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// readKVv2Data reads the latest version of a KV v2 secret, decoding the
+// response with the connection's codec
+func (vcc *VaultClientConnection) readKVv2Data(l lane.Lane, vc *vaultapi.Client, mount, path string) (data map[string]any, err error) {
+	secretPath := strings.Trim(mount, "/") + "/data/" + strings.TrimPrefix(path, "/")
+
+	// raw reads don't apply the client's timeout by themselves
+	if timeout := vc.ClientTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		l, cancel = l.DeriveWithTimeout(timeout)
+		defer cancel()
+	}
+
+	var resp *vaultapi.Response
+	resp, err = vc.Logical().ReadRawWithContext(l, secretPath)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			err = fmt.Errorf("%w: at %s", vaultapi.ErrSecretNotFound, secretPath)
+		}
+		return
+	}
+
+	var body []byte
+	if body, err = readAllLimited(resp.Body, vcc.opts.maxResponseBytes); err != nil {
+		err = fmt.Errorf("error reading secret at %s: %w", secretPath, err)
+		return
+	}
+
+	var envelope kvv2Envelope
+	if err = vcc.opts.codec.Unmarshal(body, &envelope); err != nil {
+		err = fmt.Errorf("error parsing secret at %s: %w", secretPath, err)
+		return
+	}
+
+	// a deleted or destroyed latest version has no data
+	if envelope.Data.Data == nil {
+		err = fmt.Errorf("%w: at %s", vaultapi.ErrSecretNotFound, secretPath)
+		return
+	}
+
+	data = envelope.Data.Data
+	return
+}
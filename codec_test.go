@@ -0,0 +1,143 @@
+package vaulttoken
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// countingCodec is the default codec, counting the responses it decodes
+	countingCodec struct {
+		stdJSONCodec
+		unmarshals atomic.Int32
+	}
+)
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshals.Add(1)
+	return c.stdJSONCodec.Unmarshal(data, v)
+}
+
+// newStaticClient connects to the mock server with a static token
+func newStaticClient(t testing.TB, l lane.Lane, mv *mockVault, opts ...VaultOption) *VaultClientConnection {
+	mv.addToken("static-token")
+	vcc, err := NewVaultClient(l, mv.srv.URL, "", "", "static-token", "", opts...)
+	if err != nil {
+		t.Fatalf("can't make static token client: %v", err)
+	}
+	return vcc
+}
+
+func TestReadKVv2KeepsLargeIntegers(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"id":9007199254740993,"ratio":0.25}`)
+	vcc := newStaticClient(t, l, mv)
+
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+
+	// 2^53+1 can't be held by a float64
+	id, isNumber := data["id"].(json.Number)
+	if !isNumber {
+		t.Fatalf("expected json.Number, got %T", data["id"])
+	}
+	if id.String() != "9007199254740993" {
+		t.Errorf("integer lost precision: %s", id)
+	}
+	if n, err := id.Int64(); err != nil || n != 9007199254740993 {
+		t.Errorf("expected 9007199254740993, got %d (%v)", n, err)
+	}
+	if ratio, _ := data["ratio"].(json.Number); ratio.String() != "0.25" {
+		t.Errorf("unexpected ratio %v", data["ratio"])
+	}
+}
+
+func TestWithJSONCodec(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+
+	codec := &countingCodec{}
+	vcc := newStaticClient(t, l, mv, WithJSONCodec(codec))
+
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+	if n := codec.unmarshals.Load(); n != 1 {
+		t.Errorf("expected the custom codec to decode 1 response, got %d", n)
+	}
+}
+
+func TestWithJSONCodecNil(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newStaticClient(t, l, mv, WithJSONCodec(nil))
+
+	if _, isStd := vcc.opts.codec.(stdJSONCodec); !isStd {
+		t.Fatalf("expected the default codec, got %T", vcc.opts.codec)
+	}
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+}
+
+// largeSecretJson makes a KV v2 data object with n fields
+func largeSecretJson(n int) string {
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i := range n {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		fmt.Fprintf(&sb, `"key-%d":%q`, i, strings.Repeat("v", 64))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func BenchmarkStdJSONCodecUnmarshal(b *testing.B) {
+	body := []byte(`{"data":{"data":` + largeSecretJson(500) + `,"metadata":{"version":1}}}`)
+	codec := stdJSONCodec{}
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		var envelope kvv2Envelope
+		if err := codec.Unmarshal(body, &envelope); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadKVv2(b *testing.B) {
+	l := lane.NewNullLane(context.Background())
+	mv := newMockVault(b)
+	mv.putSecret("secret", "app", largeSecretJson(500))
+	vcc := newStaticClient(b, l, mv)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := vcc.ReadKVv2(l, "secret", "app"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
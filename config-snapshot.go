@@ -0,0 +1,85 @@
+package vaulttoken
+
+import (
+	"time"
+)
+
+type (
+	// ConfigSnapshot describes how a connection is configured, so that an
+	// environment can be reproduced or compared with another. Secrets such
+	// as tokens, secret IDs and claim values are never included.
+	ConfigSnapshot struct {
+		Address               string             `json:"address"`
+		AuthMethod            string             `json:"auth_method"`
+		Role                  string             `json:"role,omitempty"`
+		AuthPath              string             `json:"auth_path,omitempty"`
+		Namespace             string             `json:"namespace,omitempty"`
+		PathPrefix            string             `json:"path_prefix,omitempty"`
+		RequestedTTL          time.Duration      `json:"requested_ttl,omitempty"`
+		Timeout               time.Duration      `json:"timeout,omitempty"`
+		RestartRetries        int                `json:"restart_retries,omitempty"`
+		MaxConcurrentRequests int                `json:"max_concurrent_requests,omitempty"`
+		MaxResponseBytes      int64              `json:"max_response_bytes,omitempty"`
+		Consistency           ConsistencyMode    `json:"consistency"`
+		ForceHTTP1            bool               `json:"force_http1,omitempty"`
+		TLS                   *TLSInfo           `json:"tls,omitempty"`
+		KubernetesTokenFile   string             `json:"kubernetes_token_file,omitempty"`
+		GCP                   *GCPConfigSnapshot `json:"gcp,omitempty"`
+	}
+
+	// GCPConfigSnapshot holds the GCP auth settings of a ConfigSnapshot.
+	// AuthType is "iam" or "gce".
+	GCPConfigSnapshot struct {
+		AuthType            string        `json:"auth_type"`
+		Audience            string        `json:"audience"`
+		Scopes              []string      `json:"scopes,omitempty"`
+		ADCFile             string        `json:"adc_file,omitempty"`
+		JwtTTL              time.Duration `json:"jwt_ttl"`
+		JwtNonce            bool          `json:"jwt_nonce,omitempty"`
+		ExtraClaimNames     []string      `json:"extra_claim_names,omitempty"`
+		CheckAudience       bool          `json:"check_audience,omitempty"`
+		SignMaxRetries      int           `json:"sign_max_retries"`
+		SignInitialInterval time.Duration `json:"sign_initial_interval,omitempty"`
+		SignMaxInterval     time.Duration `json:"sign_max_interval,omitempty"`
+		SignMaxElapsedTime  time.Duration `json:"sign_max_elapsed_time,omitempty"`
+		SignTimeout         time.Duration `json:"sign_timeout,omitempty"`
+		SignDialTimeout     time.Duration `json:"sign_dial_timeout,omitempty"`
+	}
+
+	// configSnapshotter is implemented by auth configs to fill in their
+	// part of a ConfigSnapshot
+	configSnapshotter interface {
+		snapshot(snap *ConfigSnapshot)
+	}
+)
+
+// ExportConfig provides a redacted, serializable snapshot of the
+// connection's configuration: the auth method and its paths, TTLs, retry
+// settings and TLS sources. The TLS part is omitted when the caller
+// supplied the http client.
+func (vcc *VaultClientConnection) ExportConfig() (snap ConfigSnapshot, err error) {
+	snap = ConfigSnapshot{
+		Address:               vcc.vc.Address(),
+		AuthMethod:            vcc.AuthMethod(),
+		Namespace:             vcc.opts.namespace,
+		PathPrefix:            vcc.opts.pathPrefix,
+		RequestedTTL:          vcc.opts.requestedTtl,
+		Timeout:               vcc.opts.timeout,
+		RestartRetries:        vcc.opts.restartRetries,
+		MaxConcurrentRequests: vcc.opts.maxRequests,
+		MaxResponseBytes:      vcc.opts.maxResponseBytes,
+		Consistency:           vcc.opts.consistency,
+		ForceHTTP1:            vcc.opts.forceHttp1,
+	}
+
+	if vcc.opts.httpClient == nil {
+		if snap.TLS, err = vcc.TLSInfo(); err != nil {
+			return
+		}
+	}
+
+	if s, ok := vcc.authCfg.(configSnapshotter); ok {
+		s.snapshot(&snap)
+	}
+	return
+}
@@ -0,0 +1,102 @@
+package vaulttoken
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestExportConfig(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv,
+		WithNamespace("team-a"),
+		WithPathPrefix("/vault"),
+		WithRequestedTTL(30*time.Minute),
+		WithTimeout(10*time.Second),
+		WithRestartRecovery(2),
+		WithMaxConcurrentRequests(4),
+		WithMaxResponseBytes(1<<20),
+		WithConsistency(ConsistencyReadYourWrites),
+		WithForceHTTP1(true),
+	)
+
+	// the snapshot is the same before and after a login
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	snap, err := vcc.ExportConfig()
+	if err != nil {
+		t.Fatalf("can't export config: %v", err)
+	}
+
+	want := ConfigSnapshot{
+		Address:               mv.srv.URL,
+		AuthMethod:            AuthMethodAppRole,
+		AuthPath:              "auth/approle",
+		Namespace:             "team-a",
+		PathPrefix:            "/vault",
+		RequestedTTL:          30 * time.Minute,
+		Timeout:               10 * time.Second,
+		RestartRetries:        2,
+		MaxConcurrentRequests: 4,
+		MaxResponseBytes:      1 << 20,
+		Consistency:           ConsistencyReadYourWrites,
+		ForceHTTP1:            true,
+		TLS:                   snap.TLS,
+	}
+	if snap.TLS == nil || !snap.TLS.VerifyEnabled {
+		t.Errorf("unexpected tls info %+v", snap.TLS)
+	}
+	if !jsonEqual(t, snap, want) {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+
+	// and holds no credentials or tokens
+	js, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("can't serialize the snapshot: %v", err)
+	}
+	vc, _ := vcc.GetApiInterface(l)
+	for _, secret := range []string{"secret-id", vc.Token()} {
+		if strings.Contains(string(js), secret) {
+			t.Errorf("the snapshot holds %s: %s", secret, js)
+		}
+	}
+}
+
+func TestExportConfigStaticToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	snap, err := vcc.ExportConfig()
+	if err != nil {
+		t.Fatalf("can't export config: %v", err)
+	}
+	if snap.AuthMethod != AuthMethodStatic || snap.AuthPath != "" || snap.GCP != nil {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+	js, _ := json.Marshal(snap)
+	if strings.Contains(string(js), "static-token") {
+		t.Errorf("the snapshot holds the static token: %s", js)
+	}
+}
+
+// jsonEqual compares two values by their JSON form
+func jsonEqual(t *testing.T, a, b any) bool {
+	t.Helper()
+	ja, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(ja) == string(jb)
+}
@@ -0,0 +1,36 @@
+package vaulttoken
+
+import (
+	"context"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// NewVaultClientContext is NewVaultClient for callers that use a plain
+// context rather than a lane. Cancelling ctx abandons any requests made
+// during setup, such as static token validation.
+//
+// If ctx is a lane, it is used for logging.
+func NewVaultClientContext(ctx context.Context, uri, caCert, caPath, vaultToken, vaultRole string, opts ...VaultOption) (vcc *VaultClientConnection, err error) {
+	return NewVaultClient(laneFromContext(ctx), uri, caCert, caPath, vaultToken, vaultRole, opts...)
+}
+
+// GetApiInterfaceContext is GetApiInterface for callers that use a plain
+// context rather than a lane. Cancelling ctx abandons a hung login and
+// returns the context's error.
+//
+// If ctx is a lane, it is used for logging.
+func (vcc *VaultClientConnection) GetApiInterfaceContext(ctx context.Context) (vc *vaultapi.Client, err error) {
+	return vcc.GetApiInterface(laneFromContext(ctx))
+}
+
+// laneFromContext provides ctx if it is a lane, otherwise a lane that
+// discards logging and carries ctx
+func laneFromContext(ctx context.Context) lane.Lane {
+	l, isLane := ctx.(lane.Lane)
+	if !isLane {
+		l = lane.NewNullLane(ctx)
+	}
+	return l
+}
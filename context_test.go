@@ -0,0 +1,89 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestGetApiInterfaceContextCancel(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.loginGate = make(chan struct{})
+	vcc := newAppRoleClient(t, l, mv)
+
+	// a live caller starts the shared login, which hangs at the gate
+	type result struct {
+		token string
+		err   error
+	}
+	live := make(chan result, 1)
+	go func() {
+		vc, err := vcc.GetApiInterfaceContext(context.Background())
+		if err != nil {
+			live <- result{err: err}
+			return
+		}
+		live <- result{token: vc.Token()}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// a second caller gives up without waiting for the login
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := vcc.GetApiInterfaceContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled caller took %v to return", elapsed)
+	}
+
+	// the shared login isn't tied to either caller, so the live caller
+	// still gets a token once the server responds
+	close(mv.loginGate)
+	select {
+	case r := <-live:
+		if r.err != nil {
+			t.Fatalf("live caller failed: %v", r.err)
+		}
+		if r.token == "" {
+			t.Errorf("live caller got no token")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("live caller didn't return")
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestSharedLoginOutlivesCancelledCaller(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.loginGate = make(chan struct{})
+	vcc := newAppRoleClient(t, l, mv)
+
+	// the only caller gives up, but the login it started completes
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := vcc.GetApiInterfaceContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	close(mv.loginGate)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if vc.Token() == "" {
+		t.Errorf("no token after login")
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected the abandoned login to be reused, got %d logins", n)
+	}
+}
@@ -1,17 +1,53 @@
 package vaulttoken
 
 import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/jimsnab/go-lane"
+	"golang.org/x/sync/singleflight"
 )
 
 type (
 	VaultClientConnection struct {
-		vc      *vaultapi.Client
-		auth    VaultAuth
-		authCfg VaultAuthConfig
+		vc        *vaultapi.Client
+		auth      VaultAuth
+		authCfg   VaultAuthConfig
+		role      string
+		transport VaultTransport
+		opts      *vaultOptions
+		provider  VaultToken
+		logins    singleflight.Group
+		stats     connStats
+
+		loginMu   sync.Mutex
+		lastLogin *vaultapi.Secret
+		maxTtl    time.Duration
+
+		eventMu     sync.Mutex
+		subscribers []*tokenSubscriber
+
+		renewMu       sync.Mutex
+		renewStartMu  sync.Mutex
+		stopRenew     func()
+		renewFinished chan struct{}
+		renewAuto     bool
+		nextRenewal   time.Time
+
+		tlsCfg        vaultapi.TLSConfig
+		httpTransport *http.Transport
+
+		kvMu       sync.Mutex
+		kvVersions map[string]int
+
+		clusterMu sync.Mutex
+		clusterId string
 	}
 )
 
@@ -26,21 +62,138 @@ type (
 //
 // vaultRole is the role name in the Vault server for the cloud account
 // that can mint a JWT token.
-func NewVaultClient(l lane.Lane, uri, caCert, caPath, vaultToken, vaultRole string) (vcc *VaultClientConnection, err error) {
-	vcc = &VaultClientConnection{}
+//
+// Additional behavior can be customized with opts.
+//
+// NewVaultClientWithOptions is the equivalent that takes every setting as
+// an option.
+func NewVaultClient(l lane.Lane, uri, caCert, caPath, vaultToken, vaultRole string, opts ...VaultOption) (vcc *VaultClientConnection, err error) {
+	positional := []VaultOption{
+		WithCACert(caCert),
+		WithCAPath(caPath),
+		WithStaticToken(vaultToken),
+		WithGCPRole(vaultRole),
+	}
+	return NewVaultClientWithOptions(l, uri, append(positional, opts...)...)
+}
+
+// Makes a new Vault client for the server at uri, configured by opts. With
+// WithStaticToken, the static token is used; otherwise the client logs in
+// with GCP auth using the role given by WithGCPRole. See NewVaultClient.
+func NewVaultClientWithOptions(l lane.Lane, uri string, opts ...VaultOption) (vcc *VaultClientConnection, err error) {
+	vo := newVaultOptions(opts)
+	if vcc, err = newVaultConnection(l, uri, vo); err != nil {
+		return
+	}
+	vaultToken := vo.envDefault(vo.staticToken, vaultapi.EnvVaultToken)
+
+	// if token env variable is set, use it (typical for local hosting)
+	if vaultToken != "" {
+		vcc.vc.SetToken(vaultToken)
+		vcc.setProvider(newStaticToken(vaultToken, vcc.vc, vcc.transport))
+		if vo.validate {
+			err = vcc.validateStaticToken(l)
+		}
+		return
+	}
+
+	// otherwise assume the environment is GKE with workload identity
+	// providing auth to get a JWT
+	err = vcc.attachAuth(l, newDefaultAuth(vo, &vcc.stats), vo.gcpRole)
+	return
+}
+
+// Makes a new Vault client that logs in with AppRole credentials, for
+// workloads that run outside of GCP. See NewVaultClient for the other
+// parameters.
+//
+// The AppRole mount defaults to auth/approle; see WithAppRoleAuthPath.
+func NewVaultClientAppRole(l lane.Lane, uri, caCert, caPath, roleId, secretId string, opts ...VaultOption) (vcc *VaultClientConnection, err error) {
+	vo := newVaultOptions(append([]VaultOption{WithCACert(caCert), WithCAPath(caPath)}, opts...))
+	if vcc, err = newVaultConnection(l, uri, vo); err != nil {
+		return
+	}
+
+	err = vcc.attachAuth(l, newAppRoleAuth(vo, secretId), roleId)
+	return
+}
+
+// Makes a new Vault client that logs in with the pod's Kubernetes service
+// account JWT, for clusters without GKE workload identity. See NewVaultClient
+// for the other parameters.
+//
+// The Kubernetes mount defaults to auth/kubernetes; see
+// WithKubernetesAuthPath and WithKubernetesTokenFile.
+func NewVaultClientKubernetes(l lane.Lane, uri, caCert, caPath, vaultRole string, opts ...VaultOption) (vcc *VaultClientConnection, err error) {
+	vo := newVaultOptions(append([]VaultOption{WithCACert(caCert), WithCAPath(caPath)}, opts...))
+	if vcc, err = newVaultConnection(l, uri, vo); err != nil {
+		return
+	}
+
+	err = vcc.attachAuth(l, newK8sAuth(vo), vaultRole)
+	return
+}
+
+// Makes a new Vault client that logs in with the generic jwt auth method,
+// presenting any OIDC-compliant JWT obtained from source, e.g.,
+// JwtFromFile. See NewVaultClient for the other parameters.
+//
+// The jwt mount defaults to auth/jwt; see WithJwtAuthPath.
+func NewVaultClientJwt(l lane.Lane, uri, caCert, caPath, vaultRole string, source JwtSource, opts ...VaultOption) (vcc *VaultClientConnection, err error) {
+	vo := newVaultOptions(append([]VaultOption{WithCACert(caCert), WithCAPath(caPath)}, opts...))
+	if vcc, err = newVaultConnection(l, uri, vo); err != nil {
+		return
+	}
+
+	err = vcc.attachAuth(l, newJwtAuth(vo, source), vaultRole)
+	return
+}
+
+// newVaultConnection makes the connection and its vault api client, without
+// any auth
+func newVaultConnection(l lane.Lane, uri string, vo *vaultOptions) (vcc *VaultClientConnection, err error) {
+	vcc = &VaultClientConnection{
+		transport: vo.transport,
+		opts:      vo,
+	}
+
+	uri = vo.envDefault(uri, vaultapi.EnvVaultAddress)
+	caCert, caPath := vo.caCert, vo.caPath
+	if len(vo.caCertPem) == 0 {
+		caCert, caPath = vo.envDefaultCA(caCert, caPath)
+	}
 
 	vcfg := vaultapi.DefaultConfig()
+	if vo.httpClient != nil {
+		// copied so that wrapping the transport doesn't alter the caller's client
+		hc := *vo.httpClient
+		if hc.Transport == nil {
+			// a nil transport means the default to http.Client, but the
+			// wrapping round trippers need one to call
+			hc.Transport = http.DefaultTransport
+		}
+		vcfg.HttpClient = &hc
+	}
 	vcfg.Address = uri
+	if vo.timeout > 0 {
+		vcfg.Timeout = vo.timeout
+	}
+	vcfg.ReadYourWrites = (vo.consistency == ConsistencyReadYourWrites)
 
-	tlsConfig := vaultapi.TLSConfig{
-		CACert: caCert, // server ca pem file path
-		CAPath: caPath, // server ca pem(s) dir path
+	vcc.httpTransport = vaultHttpTransport(vcfg)
+	if vo.httpClient == nil {
+		vcc.configureTLS(l, vcfg, caCert, caPath)
+	} else if caCert != "" || caPath != "" || len(vo.caCertPem) > 0 || vo.insecure || vo.forceHttp1 {
+		l.Warnf("vault client: the supplied http client takes precedence; CA, TLS and HTTP/1.1 options are not applied")
 	}
-	terr := vcfg.ConfigureTLS(&tlsConfig)
-	if terr != nil {
-		l.Warnf("vault client: tls configuration error: %v", terr)
-		wd, _ := os.Getwd()
-		l.Debugf("vault client: working dir: %s", wd)
+
+	if strings.Trim(vo.pathPrefix, "/") != "" {
+		vcfg.HttpClient.Transport = newPrefixRoundTripper(vcfg.HttpClient.Transport, vo.pathPrefix)
+	}
+
+	// the limiter wraps the configured transport, so it must come last
+	if vo.maxRequests > 0 {
+		vcfg.HttpClient.Transport = newLimitedRoundTripper(vcfg.HttpClient.Transport, vo.maxRequests)
 	}
 
 	var vc *vaultapi.Client
@@ -50,51 +203,336 @@ func NewVaultClient(l lane.Lane, uri, caCert, caPath, vaultToken, vaultRole stri
 	}
 	vcc.vc = vc
 
-	// if token env variable is set, use it (typical for local hosting)
-	if vaultToken != "" {
-		vc.SetToken(vaultToken)
-		return
+	if namespace := vo.envDefault(vo.namespace, vaultapi.EnvVaultNamespace); namespace != "" {
+		vc.SetNamespace(namespace)
 	}
+	return
+}
 
-	// otherwise assume the environment is GKE with workload identity
-	// providing auth to get a JWT
+// configureTLS applies the TLS options to the vault client config's transport
+func (vcc *VaultClientConnection) configureTLS(l lane.Lane, vcfg *vaultapi.Config, caCert, caPath string) {
+	vo := vcc.opts
+	if vcc.httpTransport != nil {
+		if vo.forceHttp1 {
+			disableHttp2(vcc.httpTransport)
+		}
+		if vcc.httpTransport.TLSClientConfig == nil {
+			vcc.httpTransport.TLSClientConfig = &tls.Config{}
+		}
+		vcc.httpTransport.TLSClientConfig.MinVersion = vo.minTls
+	}
 
-	// get a GCP auth config
-	auth := VaultAuth(&gcpAuth{})
+	vcc.tlsCfg = vaultapi.TLSConfig{
+		CACert:      caCert,       // server ca pem file path
+		CAPath:      caPath,       // server ca pem(s) dir path
+		CACertBytes: vo.caCertPem, // server ca pem held in memory
+		Insecure:    vo.insecure,
+	}
+	if vo.insecure {
+		l.Warnf("vault client: *** tls verification of the vault server is DISABLED; never do this in production ***")
+	}
+	terr := vcfg.ConfigureTLS(&vcc.tlsCfg)
+	if terr != nil {
+		l.Warnf("vault client: tls configuration error: %v", terr)
+		wd, _ := os.Getwd()
+		l.Debugf("vault client: working dir: %s", wd)
+	}
+}
+
+// attachAuth sets up the connection to log in with auth
+func (vcc *VaultClientConnection) attachAuth(l lane.Lane, auth VaultAuth, vaultRole string) (err error) {
 	vcc.auth = auth
 
 	var authCfg VaultAuthConfig
 	if authCfg, err = auth.getConfig(l, vaultRole); err != nil {
-		l.Errorf("vault client: failed to get GCP auth config: %v", err)
+		l.Errorf("vault client: failed to get %s auth config: %v", auth.authMethod(), err)
 		return
 	}
 
 	vcc.authCfg = authCfg
+	vcc.role = vaultRole
+	return
+}
+
+// AuthMethod identifies how the connection authenticates, such as
+// AuthMethodStatic, AuthMethodGcp, AuthMethodAppRole, AuthMethodKubernetes
+// or AuthMethodJwt.
+func (vcc *VaultClientConnection) AuthMethod() string {
+	if vcc.auth == nil {
+		return AuthMethodStatic
+	}
+	return vcc.auth.authMethod()
+}
+
+// IsRenewable indicates if the current token can be renewed, or if a new
+// login will be needed when it expires. The answer comes from the most recent
+// login, or from a token lookup for a static token or if no login happened yet.
+func (vcc *VaultClientConnection) IsRenewable(l lane.Lane) (renewable bool, err error) {
+	vcc.loginMu.Lock()
+	token := vcc.lastLogin
+	vcc.loginMu.Unlock()
+
+	if token != nil {
+		renewable = token.Auth.Renewable
+		return
+	}
+
+	var info *TokenInfo
+	if _, info, err = vcc.GetApiInterfaceWithInfo(l); err != nil {
+		return
+	}
+
+	renewable = info.Renewable
+	return
+}
+
+// IsRootToken indicates if the current token is a root token, judged by a
+// token lookup showing the root policy or no TTL, so an app can refuse to
+// run as root in production.
+func (vcc *VaultClientConnection) IsRootToken(l lane.Lane) (root bool, err error) {
+	var secret *vaultapi.Secret
+	if secret, err = vcc.TokenInfo(l); err != nil {
+		return
+	}
+
+	root = isRootToken(secret)
+	return
+}
+
+// validateStaticToken confirms Vault accepts the static token, and calls
+// attention to the use of a root token
+func (vcc *VaultClientConnection) validateStaticToken(l lane.Lane) (err error) {
+	var secret *vaultapi.Secret
+	if secret, err = vcc.transport.LookupSelf(l, vcc.vc); err != nil {
+		l.Errorf("vault client: static token lookup failed: %v", err)
+		return
+	}
+
+	if isRootToken(secret) {
+		l.Warnf("vault client: *** the static token is a ROOT token; never use a root token in production ***")
+	}
 	return
 }
 
 // Returns a vault client with a fresh auth token.
 // See https://github.com/hashicorp/vault-examples/blob/main/examples/_quick-start/go/example.go.
 func (vcc *VaultClientConnection) GetApiInterface(l lane.Lane) (vc *vaultapi.Client, err error) {
+	vc, _, err = vcc.getApiInterface(l)
+	return
+}
+
+// Returns a vault client with a fresh auth token, along with the details of
+// that token. For a static token, the details come from a token lookup.
+func (vcc *VaultClientConnection) GetApiInterfaceWithInfo(l lane.Lane) (vc *vaultapi.Client, info *TokenInfo, err error) {
+	var token *vaultapi.Secret
+	if vc, token, err = vcc.getApiInterface(l); err != nil {
+		return
+	}
+
+	if token == nil {
+		if token, err = vcc.transport.LookupSelf(l, vc); err != nil {
+			l.Errorf("vault client: error looking up static token: %v", err)
+			return
+		}
+	}
+
+	if info, err = newTokenInfo(token); err != nil {
+		l.Errorf("vault client: error parsing token info: %v", err)
+		return
+	}
+	return
+}
+
+// worker that provides the client and, unless the token is static, the
+// login response of the fresh token
+func (vcc *VaultClientConnection) getApiInterface(l lane.Lane) (vc *vaultapi.Client, token *vaultapi.Secret, err error) {
 	// if static token, just return the client
 	vc = vcc.vc
 	if vcc.auth == nil {
 		return
 	}
 
-	// otherwise refresh the token
-	var tokenProvider VaultToken
-	if tokenProvider, err = vcc.auth.newVaultToken(l, vcc.authCfg, vc); err != nil {
+	// reuse the current login while its token is good
+	if token = vcc.cachedLogin(l); token != nil {
+		return
+	}
+
+	token, err = vcc.relogin(l)
+	return
+}
+
+// cachedLogin provides the login response of the current token, or nil if
+// there is no token or it has expired. Revocation isn't probed here, which
+// would cost a Vault round trip per call; a request rejected for its token
+// drops the login instead, see dropLogin.
+func (vcc *VaultClientConnection) cachedLogin(l lane.Lane) *vaultapi.Secret {
+	vcc.loginMu.Lock()
+	provider := vcc.provider
+	token := vcc.lastLogin
+	vcc.loginMu.Unlock()
+
+	if provider == nil || token == nil {
+		return nil
+	}
+
+	if expired, err := provider.isExpired(l); err != nil || expired {
+		return nil
+	}
+	return token
+}
+
+// dropLogin forgets the current login if it is still the one that issued
+// clientToken, so the next GetApiInterface logs in again; a newer login is
+// left alone
+func (vcc *VaultClientConnection) dropLogin(l lane.Lane, clientToken string) {
+	vcc.loginMu.Lock()
+	defer vcc.loginMu.Unlock()
+
+	if vcc.lastLogin == nil || vcc.lastLogin.Auth == nil || vcc.lastLogin.Auth.ClientToken != clientToken {
+		return
+	}
+	l.Infof("vault client: vault rejected the current token, logging in again on next use")
+	vcc.lastLogin = nil
+	vcc.provider = nil
+}
+
+// kSharedLoginTimeout bounds a login shared by concurrent callers, which
+// isn't cancelled by any one of them
+const kSharedLoginTimeout = 2 * time.Minute
+
+// relogin replaces the current token with a fresh login, e.g., because it
+// expired or can no longer be renewed
+func (vcc *VaultClientConnection) relogin(l lane.Lane) (token *vaultapi.Secret, err error) {
+	// concurrent callers share a single login, which alone applies its token
+	// to the shared client, so a late caller can't replace a newer token
+	// with an older one; it runs on its own bounded lane, so the caller that
+	// started it giving up doesn't fail the others
+	logins := vcc.logins.DoChan(vcc.role, func() (any, error) {
+		ll, cancel := l.DeriveWithoutCancel().DeriveWithTimeout(kSharedLoginTimeout)
+		defer cancel()
+
+		token, provider, err := vcc.login(ll)
+		if err != nil {
+			return nil, err
+		}
+
+		vcc.loginMu.Lock()
+		vcc.vc.SetToken(token.Auth.ClientToken)
+		vcc.lastLogin = token
+		vcc.provider = provider
+		vcc.loginMu.Unlock()
+		return token, nil
+	})
+
+	// the caller can give up on a hung login by cancelling its context
+	var result singleflight.Result
+	select {
+	case result = <-logins:
+	case <-l.Done():
+		err = l.Err()
+		return
+	}
+	if err = result.Err; err != nil {
+		return
+	}
+
+	token = result.Val.(*vaultapi.Secret)
+	return
+}
+
+// login creates a token provider and performs a vault login with it
+func (vcc *VaultClientConnection) login(l lane.Lane) (token *vaultapi.Secret, tokenProvider VaultToken, err error) {
+	if vcc.opts.beforeLogin != nil {
+		if err = vcc.opts.beforeLogin(l); err != nil {
+			l.Errorf("vault client: login vetoed by before-login hook: %v", err)
+			return
+		}
+	}
+
+	if tokenProvider, err = vcc.auth.newVaultToken(l, vcc.authCfg, vcc.vc); err != nil {
 		l.Errorf("vault client: error creating auth token: %v", err)
 		return
 	}
 
-	var token *vaultapi.Secret
 	if token, err = tokenProvider.getToken(l); err != nil {
 		l.Errorf("vault client: error in vault authentication: %v", err)
+		vcc.stats.recordError(err)
+		err = fmt.Errorf("%w: %w", ErrLoginFailed, err)
+		return
+	}
+
+	if err = vcc.checkLoginToken(l, tokenProvider, token); err != nil {
+		return
+	}
+
+	vcc.captureExplicitMaxTtl(l, token)
+	vcc.stats.logins.Add(1)
+	ttl, _ := token.TokenTTL()
+	vcc.publishTokenEvent(TokenLoggedIn, ttl, nil)
+	return
+}
+
+// setProvider records the provider of the connection's live token
+func (vcc *VaultClientConnection) setProvider(provider VaultToken) {
+	vcc.loginMu.Lock()
+	vcc.provider = provider
+	vcc.loginMu.Unlock()
+}
+
+// captureExplicitMaxTtl records the explicit max TTL of a freshly logged in
+// token, which login responses don't carry, so a lookup is needed unless the
+// response has it
+func (vcc *VaultClientConnection) captureExplicitMaxTtl(l lane.Lane, token *vaultapi.Secret) {
+	var maxTtl time.Duration
+	if token.Data != nil && token.Data["explicit_max_ttl"] != nil {
+		maxTtl, _ = explicitMaxTtl(token)
+	} else {
+		client, err := vcc.vc.CloneWithHeaders()
+		if err != nil {
+			return
+		}
+		client.SetToken(token.Auth.ClientToken)
+
+		var lookup *vaultapi.Secret
+		if lookup, err = vcc.transport.LookupSelf(l, client); err != nil {
+			l.Debugf("vault client: can't look up the explicit max ttl of the new token: %v", err)
+			return
+		}
+		maxTtl, _ = explicitMaxTtl(lookup)
+	}
+
+	vcc.loginMu.Lock()
+	vcc.maxTtl = maxTtl
+	vcc.loginMu.Unlock()
+}
+
+// ExplicitMaxTTL provides the explicit max TTL of the current login token,
+// the hard ceiling beyond which renewal is impossible and a new login is
+// required. It is captured at login; zero means there is no explicit max.
+func (vcc *VaultClientConnection) ExplicitMaxTTL() time.Duration {
+	vcc.loginMu.Lock()
+	defer vcc.loginMu.Unlock()
+	return vcc.maxTtl
+}
+
+// LoginCount provides the number of successful Vault logins the connection
+// has performed. A count growing faster than the token TTL warrants points
+// to a token caching problem.
+func (vcc *VaultClientConnection) LoginCount() uint64 {
+	return vcc.stats.logins.Load()
+}
+
+// CurrentToken returns a fresh Vault client token, e.g., for passing to a
+// child process as VAULT_TOKEN.
+//
+// The token is a credential. Do not log it or store it anywhere longer lived
+// than its intended use; this package never writes it to the lane.
+func (vcc *VaultClientConnection) CurrentToken(l lane.Lane) (token string, err error) {
+	var vc *vaultapi.Client
+	if vc, err = vcc.GetApiInterface(l); err != nil {
 		return
 	}
 
-	vc.SetToken(token.Auth.ClientToken)
+	token = vc.Token()
 	return
 }
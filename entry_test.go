@@ -0,0 +1,474 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestConcurrentLoginsCollapse(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.loginGate = make(chan struct{})
+	vcc := newAppRoleClient(t, l, mv)
+
+	const callers = 20
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vc, err := vcc.GetApiInterface(l)
+			errs[i] = err
+			if err == nil {
+				tokens[i] = vc.Token()
+			}
+		}(i)
+	}
+
+	// hold the login until the callers pile up behind it
+	time.Sleep(50 * time.Millisecond)
+	close(mv.loginGate)
+	wg.Wait()
+
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("caller %d failed: %v", i, errs[i])
+		}
+		if tokens[i] != tokens[0] {
+			t.Errorf("caller %d got token %s, caller 0 got %s", i, tokens[i], tokens[0])
+		}
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestExpiredTokenLoginsCollapse(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.ttl = 60
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	expired := vc.Token()
+
+	// the short-lived token expires, and the next login is held until the
+	// callers pile up behind it
+	fc.advance(fc.Now().Add(2 * time.Minute))
+	gate := make(chan struct{})
+	mv.mu.Lock()
+	mv.loginGate = gate
+	mv.mu.Unlock()
+
+	const callers = 20
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			vc, err := vcc.GetApiInterface(l)
+			errs[i] = err
+			if err == nil {
+				tokens[i] = vc.Token()
+			}
+		}(i)
+	}
+
+	waitForInFlight(t, mv, 1)
+	time.Sleep(50 * time.Millisecond)
+	close(gate)
+	wg.Wait()
+
+	for i := range callers {
+		if errs[i] != nil {
+			t.Fatalf("caller %d failed: %v", i, errs[i])
+		}
+		if tokens[i] == expired || tokens[i] != tokens[0] {
+			t.Errorf("caller %d got token %s, caller 0 got %s", i, tokens[i], tokens[0])
+		}
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected exactly one more login, got %d in all", n)
+	}
+}
+
+func TestExplicitMaxTTL(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.explicitMaxTtl = 7200
+	vcc := newAppRoleClient(t, l, mv)
+
+	if ttl := vcc.ExplicitMaxTTL(); ttl != 0 {
+		t.Errorf("expected no max ttl before login, got %v", ttl)
+	}
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if ttl := vcc.ExplicitMaxTTL(); ttl != 2*time.Hour {
+		t.Errorf("expected a max ttl of 2h, got %v", ttl)
+	}
+}
+
+func TestExplicitMaxTTLNone(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if ttl := vcc.ExplicitMaxTTL(); ttl != 0 {
+		t.Errorf("expected no max ttl, got %v", ttl)
+	}
+}
+
+func TestGetApiInterfaceWithInfo(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.ttl = 1800
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, info, err := vcc.GetApiInterfaceWithInfo(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if vc.Token() != "tok-1" {
+		t.Errorf("unexpected token %s", vc.Token())
+	}
+	if info.Accessor != "acc-tok-1" {
+		t.Errorf("unexpected accessor %s", info.Accessor)
+	}
+	if len(info.Policies) != 1 || info.Policies[0] != "default" {
+		t.Errorf("unexpected policies %v", info.Policies)
+	}
+	if info.TTL != 30*time.Minute {
+		t.Errorf("unexpected ttl %s", info.TTL)
+	}
+	if !info.Renewable {
+		t.Error("expected a renewable token")
+	}
+
+	// the info comes from the login, not a lookup
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestGetApiInterfaceWithInfoStatic(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.ttl = 600
+	vcc := newStaticClient(t, l, mv)
+
+	_, info, err := vcc.GetApiInterfaceWithInfo(l)
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if info.Accessor != "acc-static-token" || info.TTL != 10*time.Minute {
+		t.Errorf("unexpected info %+v", info)
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no login, got %d", n)
+	}
+}
+
+func TestCurrentToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	l.WantDescendantEvents(true)
+	mv := newMockVault(t)
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	token, err := vcc.CurrentToken(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if token != "tok-1" {
+		t.Errorf("unexpected token %s", token)
+	}
+
+	// an expired token is replaced before it is handed out
+	fc.advance(fc.Now().Add(2 * time.Hour))
+	if token, err = vcc.CurrentToken(l); err != nil {
+		t.Fatalf("re-login failed: %v", err)
+	}
+	if token != "tok-2" {
+		t.Errorf("expected the fresh token, got %s", token)
+	}
+
+	if events := l.EventsToString(); strings.Contains(events, "tok-") {
+		t.Errorf("a token was logged:\n%s", events)
+	}
+}
+
+func TestTokenValidationWarnsOfRootToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.rootTokens = true
+
+	newStaticClient(t, l, mv, WithTokenValidation())
+	if !strings.Contains(l.EventsToString(), "ROOT token") {
+		t.Errorf("expected a root token warning:\n%s", l.EventsToString())
+	}
+}
+
+func TestTokenValidationNonRoot(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	newStaticClient(t, l, mv, WithTokenValidation())
+	if n := mv.lookups.Load(); n != 1 {
+		t.Errorf("expected 1 lookup, got %d", n)
+	}
+	if strings.Contains(l.EventsToString(), "ROOT token") {
+		t.Errorf("unexpected root token warning:\n%s", l.EventsToString())
+	}
+}
+
+func TestTokenValidationOff(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.rootTokens = true
+
+	// without the option, the token isn't looked up
+	newStaticClient(t, l, mv)
+	if n := mv.lookups.Load(); n != 0 {
+		t.Errorf("expected no lookup, got %d", n)
+	}
+	if strings.Contains(l.EventsToString(), "ROOT token") {
+		t.Errorf("unexpected root token warning:\n%s", l.EventsToString())
+	}
+}
+
+func TestIsRootToken(t *testing.T) {
+	for _, root := range []bool{false, true} {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+		mv.rootTokens = root
+		vcc := newStaticClient(t, l, mv)
+
+		isRoot, err := vcc.IsRootToken(l)
+		if err != nil {
+			t.Fatalf("can't look up the token: %v", err)
+		}
+		if isRoot != root {
+			t.Errorf("expected root %v, got %v", root, isRoot)
+		}
+		if n := mv.lookups.Load(); n != 1 {
+			t.Errorf("expected 1 lookup, got %d", n)
+		}
+	}
+}
+
+func TestIsRootTokenRefusedToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+	mv.revoke("static-token")
+
+	if _, err := vcc.IsRootToken(l); err == nil {
+		t.Error("expected a revoked token's lookup to fail")
+	}
+}
+
+func TestTokenValidationRefusedToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	if _, err := NewVaultClient(l, mv.srv.URL, "", "", "unknown-token", "", WithTokenValidation()); err == nil {
+		t.Error("expected a token Vault doesn't accept to fail validation")
+	}
+}
+
+func TestBeforeLoginRunsBeforeEachLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	var hookRuns int
+	hook := func(l lane.Lane) error {
+		// the hook runs before the login request is made
+		if n := int(mv.logins.Load()); n != hookRuns {
+			t.Errorf("hook run %d found %d logins", hookRuns+1, n)
+		}
+		hookRuns++
+		return nil
+	}
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, WithBeforeLogin(hook), withClock(fc))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if hookRuns != 1 {
+		t.Errorf("expected the hook to run once, ran %d times", hookRuns)
+	}
+
+	// the re-login after expiry runs the hook again
+	fc.advance(fc.Now().Add(2 * time.Hour))
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("re-login failed: %v", err)
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Fatalf("expected a re-login, have %d logins", n)
+	}
+	if hookRuns != 2 {
+		t.Errorf("expected the hook to run twice, ran %d times", hookRuns)
+	}
+}
+
+func TestBeforeLoginVetoesLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	errVeto := errors.New("credential file is stale")
+	vcc := newAppRoleClient(t, l, mv, WithBeforeLogin(func(l lane.Lane) error { return errVeto }))
+
+	if _, err := vcc.GetApiInterface(l); !errors.Is(err, errVeto) {
+		t.Errorf("expected the hook's error, got %v", err)
+	}
+	if path := mv.lastLoginPath(); path != "" {
+		t.Errorf("the vetoed login was sent to %s", path)
+	}
+}
+
+func TestAuthMethod(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("k8s.jwt.value"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	jwtSource := func() (string, error) { return "oidc.jwt.value", nil }
+
+	cases := []struct {
+		method    string
+		connect   func(l lane.Lane, mv *mockVault) (*VaultClientConnection, error)
+		loginPath string
+	}{
+		{AuthMethodStatic, func(l lane.Lane, mv *mockVault) (*VaultClientConnection, error) {
+			mv.addToken("static-token")
+			return NewVaultClient(l, mv.srv.URL, "", "", "static-token", "")
+		}, ""},
+		{AuthMethodAppRole, func(l lane.Lane, mv *mockVault) (*VaultClientConnection, error) {
+			return NewVaultClientAppRole(l, mv.srv.URL, "", "", "role-id", "secret-id")
+		}, "auth/approle/login"},
+		{AuthMethodKubernetes, func(l lane.Lane, mv *mockVault) (*VaultClientConnection, error) {
+			return NewVaultClientKubernetes(l, mv.srv.URL, "", "", "my-role", WithKubernetesTokenFile(tokenFile))
+		}, "auth/kubernetes/login"},
+		{AuthMethodJwt, func(l lane.Lane, mv *mockVault) (*VaultClientConnection, error) {
+			return NewVaultClientJwt(l, mv.srv.URL, "", "", "my-role", jwtSource)
+		}, "auth/jwt/login"},
+	}
+	for _, c := range cases {
+		t.Run(c.method, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			vcc, err := c.connect(l, mv)
+			if err != nil {
+				t.Fatalf("can't connect: %v", err)
+			}
+
+			if method := vcc.AuthMethod(); method != c.method {
+				t.Errorf("expected auth method %q, got %q", c.method, method)
+			}
+			if _, err = vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("can't get the api: %v", err)
+			}
+			if path := mv.lastLoginPath(); path != c.loginPath {
+				t.Errorf("expected a login at %q, got %q", c.loginPath, path)
+			}
+		})
+	}
+}
+
+func TestLoginCount(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	if n := vcc.LoginCount(); n != 0 {
+		t.Errorf("expected no logins before first use, got %d", n)
+	}
+
+	// within the ttl, every call reuses the one login
+	for range 10 {
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Fatalf("can't get the api: %v", err)
+		}
+	}
+	if n := vcc.LoginCount(); n != 1 {
+		t.Errorf("expected a login count of 1, got %d", n)
+	}
+
+	// past the ttl, the next call logs in once more
+	fc.advance(fc.Now().Add(2 * time.Hour))
+	for range 10 {
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Fatalf("can't get the api: %v", err)
+		}
+	}
+	if n := vcc.LoginCount(); n != 2 {
+		t.Errorf("expected a login count of 2, got %d", n)
+	}
+	if n := mv.logins.Load(); int(n) != int(vcc.LoginCount()) {
+		t.Errorf("the count %d doesn't match the %d logins at the server", vcc.LoginCount(), n)
+	}
+
+	// a failed login isn't counted
+	mv.mu.Lock()
+	mv.rejectLogins = true
+	mv.mu.Unlock()
+	fc.advance(fc.Now().Add(2 * time.Hour))
+	if _, err := vcc.GetApiInterface(l); err == nil {
+		t.Fatal("expected the login to fail")
+	}
+	if n := vcc.LoginCount(); n != 2 {
+		t.Errorf("expected the failed login not to count, got %d", n)
+	}
+}
+
+func TestIsRenewable(t *testing.T) {
+	connects := []struct {
+		name    string
+		connect func(t testing.TB, l lane.Lane, mv *mockVault, opts ...VaultOption) *VaultClientConnection
+	}{
+		{"login", newAppRoleClient},
+		{"static", newStaticClient},
+	}
+	for _, c := range connects {
+		for _, renewable := range []bool{true, false} {
+			t.Run(fmt.Sprintf("%s renewable %v", c.name, renewable), func(t *testing.T) {
+				l := lane.NewTestingLane(context.Background())
+				mv := newMockVault(t)
+				mv.nonRenewable = !renewable
+				vcc := c.connect(t, l, mv)
+
+				got, err := vcc.IsRenewable(l)
+				if err != nil {
+					t.Fatalf("can't tell if renewable: %v", err)
+				}
+				if got != renewable {
+					t.Errorf("expected renewable %v, got %v", renewable, got)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package vaulttoken
+
+import (
+	"os"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+// WithEnvDefaults makes the client fall back to the Vault CLI environment
+// variables for parameters left empty: VAULT_ADDR for the uri, VAULT_CACERT
+// and VAULT_CAPATH when neither caCert nor caPath is given, VAULT_TOKEN for
+// the static token, and VAULT_NAMESPACE for the namespace.
+func WithEnvDefaults() VaultOption {
+	return func(opts *vaultOptions) {
+		opts.envDefaults = true
+	}
+}
+
+// envDefault provides value, or if it is empty and env defaults are enabled,
+// the value of the env variable
+func (vo *vaultOptions) envDefault(value, envName string) string {
+	if value == "" && vo.envDefaults {
+		value = os.Getenv(envName)
+	}
+	return value
+}
+
+// envDefaultCA provides the CA cert file and dir, falling back to the env
+// variables only when neither is specified
+func (vo *vaultOptions) envDefaultCA(caCert, caPath string) (string, string) {
+	if caCert == "" && caPath == "" {
+		caCert = vo.envDefault(caCert, vaultapi.EnvVaultCACert)
+		caPath = vo.envDefault(caPath, vaultapi.EnvVaultCAPath)
+	}
+	return caCert, caPath
+}
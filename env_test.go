@@ -0,0 +1,103 @@
+package vaulttoken
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestEnvDefaultsAddrAndToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.addToken("env-token")
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	t.Setenv("VAULT_ADDR", mv.srv.URL)
+	t.Setenv("VAULT_TOKEN", "env-token")
+
+	vcc, err := NewVaultClientWithOptions(l, "", WithEnvDefaults())
+	if err != nil {
+		t.Fatalf("can't connect: %v", err)
+	}
+	if method := vcc.AuthMethod(); method != AuthMethodStatic {
+		t.Errorf("expected VAULT_TOKEN to make a static token client, got %s", method)
+	}
+	if _, err = vcc.ReadKVv2(l, "secret", "app"); err != nil {
+		t.Fatalf("read at VAULT_ADDR with VAULT_TOKEN failed: %v", err)
+	}
+	if token, _ := vcc.CurrentToken(l); token != "env-token" {
+		t.Errorf("expected the env token, got %s", token)
+	}
+}
+
+func TestEnvDefaultsNamespace(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	t.Setenv("VAULT_NAMESPACE", "team-a")
+
+	vcc := newStaticClient(t, l, mv, WithEnvDefaults())
+	if _, err := vcc.ReadKVv2(l, "secret", "app"); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	mv.mu.Lock()
+	namespace := mv.lastNamespace
+	mv.mu.Unlock()
+	if namespace != "team-a" {
+		t.Errorf("expected the request in namespace team-a, got %q", namespace)
+	}
+}
+
+func TestEnvDefaultsCA(t *testing.T) {
+	cases := []struct {
+		env      string
+		source   string
+		location func(dir string) string
+	}{
+		{"VAULT_CACERT", CASourceFile, func(dir string) string { return filepath.Join(dir, "ca.pem") }},
+		{"VAULT_CAPATH", CASourcePath, func(dir string) string { return dir }},
+	}
+	for _, c := range cases {
+		t.Run(c.env, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVaultTLS(t, nil)
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "ca.pem"), mv.caPem(), 0600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv(c.env, c.location(dir))
+
+			// the env CA is what lets the client trust the server
+			vcc := newAppRoleClient(t, l, mv, WithEnvDefaults())
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			info, err := vcc.TLSInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.CASource != c.source {
+				t.Errorf("expected ca source %s, got %s", c.source, info.CASource)
+			}
+		})
+	}
+}
+
+func TestEnvDefaultsDontOverrideParameters(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	t.Setenv("VAULT_ADDR", "http://127.0.0.1:1")
+	t.Setenv("VAULT_TOKEN", "env-token")
+
+	// the uri and token given by the caller win
+	vcc := newStaticClient(t, l, mv, WithEnvDefaults())
+	if _, err := vcc.ReadKVv2(l, "secret", "app"); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if token, _ := vcc.CurrentToken(l); token != "static-token" {
+		t.Errorf("expected the given token, got %s", token)
+	}
+}
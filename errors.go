@@ -0,0 +1,44 @@
+package vaulttoken
+
+import (
+	"errors"
+)
+
+// Errors for the common failure modes, matched with errors.Is. The
+// underlying cause remains in the error chain.
+var (
+	// ErrLoginFailed is reported when a Vault login doesn't produce a token.
+	ErrLoginFailed = errors.New("vault login failed")
+
+	// ErrJWTSigningFailed is reported when the signed JWT presented at a GCP
+	// login can't be obtained.
+	ErrJWTSigningFailed = errors.New("jwt signing failed")
+
+	// ErrGCPTokenSource is reported when the GCP access token needed to
+	// call Google can't be obtained, e.g., the credentials expired and can't
+	// refresh, as opposed to a failure of the IAM call itself.
+	ErrGCPTokenSource = errors.New("can't get gcp access token")
+
+	// ErrTokenExpired is reported when a token is used or renewed after its
+	// TTL has run out.
+	ErrTokenExpired = errors.New("vault token expired")
+
+	// ErrTokenRevoked is reported when a token is used or renewed after it
+	// has been revoked.
+	ErrTokenRevoked = errors.New("vault token revoked")
+
+	// ErrNoToken is reported when an operation needs the connection's token
+	// and there isn't one, e.g., it hasn't logged in yet, or the token was
+	// revoked by RevokeToken.
+	ErrNoToken = errors.New("vault connection has no token")
+
+	// ErrClientClone is reported when a token check can't proceed because
+	// the vault api client couldn't be copied to carry the token. It says
+	// nothing about the token itself; the check can be repeated.
+	ErrClientClone = errors.New("can't clone vault api client")
+
+	// ErrNoAuthConfigured is reported when an operation needs a login-based
+	// auth method and the connection has none, e.g., it uses a static token,
+	// or GCP auth was excluded from the build.
+	ErrNoAuthConfigured = errors.New("no vault auth configured")
+)
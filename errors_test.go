@@ -0,0 +1,107 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestErrLoginFailed(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.rejectLogins = true
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); !errors.Is(err, ErrLoginFailed) {
+		t.Errorf("expected ErrLoginFailed, got %v", err)
+	}
+}
+
+func TestErrTokenExpired(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	fc.advance(fc.Now().Add(2 * time.Hour))
+
+	err := vcc.RefreshToken(l, 3600)
+	if !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expected ErrTokenExpired, got %v", err)
+	}
+	if errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("an expired token isn't revoked: %v", err)
+	}
+}
+
+func TestErrTokenRevoked(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// revoked behind the connection's back
+	mv.revoke(vc.Token())
+	if err = vcc.RefreshToken(l, 3600); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestErrTokenRevokedStatic(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	mv.revoke("static-token")
+	if err := vcc.RefreshToken(l, 3600); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected ErrTokenRevoked, got %v", err)
+	}
+}
+
+func TestErrNoToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	// nothing has logged in yet
+	if err := vcc.RefreshToken(l, 3600); !errors.Is(err, ErrNoToken) {
+		t.Errorf("expected ErrNoToken before login, got %v", err)
+	}
+
+	// nor after the token is revoked
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if err := vcc.RevokeToken(l); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if _, err := vcc.IsTokenExpired(l); !errors.Is(err, ErrNoToken) {
+		t.Errorf("expected ErrNoToken after revoke, got %v", err)
+	}
+
+	// a provider without a token doesn't claim it was revoked
+	err := (&loginToken{}).refresh(l, 3600)
+	if !errors.Is(err, ErrNoToken) || errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("expected only ErrNoToken, got %v", err)
+	}
+}
+
+func TestErrNoAuthConfigured(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	if _, err := vcc.StartLifetimeWatcher(l); !errors.Is(err, ErrNoAuthConfigured) {
+		t.Errorf("expected ErrNoAuthConfigured, got %v", err)
+	}
+}
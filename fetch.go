@@ -0,0 +1,35 @@
+package vaulttoken
+
+import (
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// Config holds the NewVaultClient parameters for one-shot helpers such
+	// as FetchSecret.
+	Config struct {
+		Uri        string // vault server address
+		CACert     string // optional server ca pem file path
+		CAPath     string // optional server ca pem(s) dir path
+		VaultToken string // static token for local development/testing
+		VaultRole  string // vault role of the cloud account
+		Options    []VaultOption
+	}
+)
+
+// FetchSecret connects to Vault, authenticates, reads the KV v2 secret at
+// mount/path and then revokes the login token. It is intended for one-shot
+// CLI or cron use where keeping a VaultClientConnection around is overkill.
+//
+// A static token in cfg is not revoked.
+func FetchSecret(l lane.Lane, cfg Config, mount, path string) (data map[string]any, err error) {
+	var vcc *VaultClientConnection
+	if vcc, err = NewVaultClient(l, cfg.Uri, cfg.CACert, cfg.CAPath, cfg.VaultToken, cfg.VaultRole, cfg.Options...); err != nil {
+		return
+	}
+
+	defer vcc.release(l)
+
+	data, err = vcc.ReadKVv2(l, mount, path)
+	return
+}
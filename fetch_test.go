@@ -0,0 +1,51 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+func TestFetchSecret(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.addToken("static-token")
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+
+	data, err := FetchSecret(l, Config{Uri: mv.srv.URL, VaultToken: "static-token"}, "secret", "app")
+	if err != nil {
+		t.Fatalf("fetch failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+
+	// a static token belongs to the caller, so it isn't revoked
+	if n := mv.revocations.Load(); n != 0 {
+		t.Errorf("static token was revoked %d times", n)
+	}
+}
+
+func TestFetchSecretNotFound(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.addToken("static-token")
+
+	_, err := FetchSecret(l, Config{Uri: mv.srv.URL, VaultToken: "static-token"}, "secret", "missing")
+	if !errors.Is(err, vaultapi.ErrSecretNotFound) {
+		t.Errorf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestFetchSecretBadToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+
+	if _, err := FetchSecret(l, Config{Uri: mv.srv.URL, VaultToken: "unknown"}, "secret", "app"); err == nil {
+		t.Errorf("expected an error for a token vault doesn't accept")
+	}
+}
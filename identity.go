@@ -0,0 +1,93 @@
+package vaulttoken
+
+import (
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// GetIdentityToken obtains a Vault-signed OIDC identity token for the entity
+// of the current token, using the named identity token role. The result is a
+// JWT the application can present to other services.
+func (vcc *VaultClientConnection) GetIdentityToken(l lane.Lane, role string) (token string, err error) {
+	var secret *vaultapi.Secret
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		secret, err = vc.Logical().ReadWithContext(l, "identity/oidc/token/"+role)
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: can't get identity token for role %s: %v", role, err)
+		return
+	}
+
+	if secret != nil {
+		token, _ = secret.Data["token"].(string)
+	}
+	if token == "" {
+		err = fmt.Errorf("no identity token returned for role %s", role)
+		l.Errorf("vault client: %v", err)
+		return
+	}
+	return
+}
+
+// TokenGroups provides the names of the identity groups the current token's
+// entity belongs to. Reading the entity and its groups requires read access
+// to identity/entity/id and identity/group/id.
+func (vcc *VaultClientConnection) TokenGroups(l lane.Lane) (groups []string, err error) {
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		groups, err = vcc.tokenGroups(l, vc)
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: can't get token groups: %v", err)
+		return
+	}
+	return
+}
+
+// worker that follows the token to its entity and the entity to its groups
+func (vcc *VaultClientConnection) tokenGroups(l lane.Lane, vc *vaultapi.Client) (groups []string, err error) {
+	var self *vaultapi.Secret
+	if self, err = vcc.transport.LookupSelf(l, vc); err != nil {
+		return
+	}
+
+	entityId, _ := self.Data["entity_id"].(string)
+	if entityId == "" {
+		l.Debugf("vault client: token has no identity entity")
+		return
+	}
+
+	var entity *vaultapi.Secret
+	if entity, err = vc.Logical().ReadWithContext(l, "identity/entity/id/"+entityId); err != nil {
+		return
+	}
+	if entity == nil {
+		err = fmt.Errorf("identity entity %s not found", entityId)
+		return
+	}
+
+	groupIds, _ := entity.Data["group_ids"].([]any)
+	for _, item := range groupIds {
+		groupId, _ := item.(string)
+		if groupId == "" {
+			continue
+		}
+
+		var group *vaultapi.Secret
+		if group, err = vc.Logical().ReadWithContext(l, "identity/group/id/"+groupId); err != nil {
+			return
+		}
+
+		name := groupId
+		if group != nil {
+			if groupName, _ := group.Data["name"].(string); groupName != "" {
+				name = groupName
+			}
+		}
+		groups = append(groups, name)
+	}
+	return
+}
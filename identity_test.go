@@ -0,0 +1,65 @@
+package vaulttoken
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestGetIdentityToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.addOidcRole("payments")
+	vcc := newAppRoleClient(t, l, mv)
+
+	token, err := vcc.GetIdentityToken(l, "payments")
+	if err != nil {
+		t.Fatalf("can't get identity token: %v", err)
+	}
+
+	// issued for the role, to the connection's logged in token
+	if token != "oidc.payments.tok-1" {
+		t.Errorf("unexpected identity token %q", token)
+	}
+}
+
+func TestGetIdentityTokenUnknownRole(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if token, err := vcc.GetIdentityToken(l, "nobody"); err == nil || token != "" {
+		t.Errorf("expected an unknown role to fail, got %q", token)
+	}
+}
+
+func TestTokenGroups(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.groupNames = []string{"payments-admins", "on-call"}
+	vcc := newAppRoleClient(t, l, mv)
+
+	groups, err := vcc.TokenGroups(l)
+	if err != nil {
+		t.Fatalf("can't get token groups: %v", err)
+	}
+	if !slices.Equal(groups, []string{"payments-admins", "on-call"}) {
+		t.Errorf("unexpected groups %v", groups)
+	}
+}
+
+func TestTokenGroupsWithoutEntity(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	groups, err := vcc.TokenGroups(l)
+	if err != nil {
+		t.Fatalf("can't get token groups: %v", err)
+	}
+	if len(groups) != 0 {
+		t.Errorf("expected no groups for a token without an entity, got %v", groups)
+	}
+}
@@ -1,10 +1,20 @@
 package vaulttoken
 
 import (
+	"time"
+
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/jimsnab/go-lane"
 )
 
+const (
+	AuthMethodStatic     = "static"
+	AuthMethodGcp        = "gcp"
+	AuthMethodAppRole    = "approle"
+	AuthMethodKubernetes = "kubernetes"
+	AuthMethodJwt        = "jwt"
+)
+
 type (
 	VaultAuthConfig interface {
 	}
@@ -15,9 +25,12 @@ type (
 		isRevoked(l lane.Lane) (bool, error)
 		refresh(l lane.Lane, nextTtlInSeconds int) error
 		revoke(l lane.Lane) error
+		expiresAt() time.Time
+		renewed(ttl time.Duration)
 	}
 
 	VaultAuth interface {
+		authMethod() string
 		getConfig(l lane.Lane, vaultRole string) (VaultAuthConfig, error)
 		newVaultToken(l lane.Lane, authCfg VaultAuthConfig, client *vaultapi.Client) (VaultToken, error)
 	}
@@ -0,0 +1,232 @@
+package vaulttoken
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// ReadKVv2 reads the latest version of a secret from the KV version 2 engine
+// at mount, using a fresh token. The secret's data map is returned.
+func (vcc *VaultClientConnection) ReadKVv2(l lane.Lane, mount, path string) (data map[string]any, err error) {
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		data, err = vcc.readKVv2Data(l, vc, mount, path)
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: error reading %s/%s: %v", mount, path, err)
+		return
+	}
+	return
+}
+
+// ReadManyKVv2 reads several secrets from the KV version 2 engine at mount,
+// returning each secret's data map keyed by path. A path that fails doesn't
+// stop the others: the successfully read secrets are returned along with a
+// joined error that lists each failed path.
+func (vcc *VaultClientConnection) ReadManyKVv2(l lane.Lane, mount string, paths []string) (secrets map[string]map[string]any, err error) {
+	// a failed login fails every path, so it is reported once
+	if _, err = vcc.GetApiInterface(l); err != nil {
+		return
+	}
+
+	secrets = make(map[string]map[string]any, len(paths))
+	var errs []error
+	for _, path := range paths {
+		var data map[string]any
+		readErr := vcc.do(l, func(vc *vaultapi.Client) (err error) {
+			data, err = vcc.readKVv2Data(l, vc, mount, path)
+			return
+		})
+		if readErr != nil {
+			l.Errorf("vault client: error reading %s/%s: %v", mount, path, readErr)
+			errs = append(errs, fmt.Errorf("%s: %w", path, readErr))
+			continue
+		}
+		secrets[path] = data
+	}
+
+	err = errors.Join(errs...)
+	return
+}
+
+// ReadKVv2Versions reads specific versions of a KV v2 secret concurrently,
+// returning each version's data map keyed by version number. Versions that
+// are deleted, destroyed or don't exist are skipped with a warning; any other
+// failure is returned as a joined error alongside the versions that were read.
+func (vcc *VaultClientConnection) ReadKVv2Versions(l lane.Lane, mount, path string, versions []int) (secrets map[int]map[string]any, err error) {
+	// a failed login fails every version, so it is reported once
+	if _, err = vcc.GetApiInterface(l); err != nil {
+		return
+	}
+
+	secrets = make(map[int]map[string]any, len(versions))
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, version := range versions {
+		wg.Add(1)
+		go func(version int) {
+			defer wg.Done()
+
+			var secret *vaultapi.KVSecret
+			readErr := vcc.do(l, func(vc *vaultapi.Client) (err error) {
+				secret, err = vc.KVv2(mount).GetVersion(l, path, version)
+				return
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case errors.Is(readErr, vaultapi.ErrSecretNotFound):
+				l.Warnf("vault client: %s/%s version %d not found, skipping", mount, path, version)
+			case readErr != nil:
+				l.Errorf("vault client: error reading %s/%s version %d: %v", mount, path, version, readErr)
+				errs = append(errs, fmt.Errorf("version %d: %w", version, readErr))
+			case secret.Data == nil || secret.VersionMetadata == nil || secret.VersionMetadata.Destroyed || !secret.VersionMetadata.DeletionTime.IsZero():
+				l.Warnf("vault client: %s/%s version %d is deleted or destroyed, skipping", mount, path, version)
+			default:
+				secrets[version] = secret.Data
+			}
+		}(version)
+	}
+	wg.Wait()
+
+	err = errors.Join(errs...)
+	return
+}
+
+// ReadKVv2IfChanged reads a KV v2 secret only if its latest version differs
+// from sinceVersion, for hot-polled config. The secret's metadata is checked
+// first; when the version is unchanged, no data is fetched and changed is
+// false. newVersion is the secret's current version either way.
+func (vcc *VaultClientConnection) ReadKVv2IfChanged(l lane.Lane, mount, path string, sinceVersion int) (data map[string]any, newVersion int, changed bool, err error) {
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		var meta *vaultapi.KVMetadata
+		if meta, err = vc.KVv2(mount).GetMetadata(l, path); err != nil {
+			return
+		}
+
+		newVersion = meta.CurrentVersion
+		if newVersion == sinceVersion {
+			return
+		}
+
+		var secret *vaultapi.KVSecret
+		if secret, err = vc.KVv2(mount).GetVersion(l, path, newVersion); err != nil {
+			return
+		}
+
+		// a deleted or destroyed latest version has no data
+		if secret.Data == nil {
+			err = fmt.Errorf("%w: %s/%s version %d is deleted or destroyed", vaultapi.ErrSecretNotFound, mount, path, newVersion)
+			return
+		}
+
+		data = secret.Data
+		changed = true
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: error reading %s/%s: %v", mount, path, err)
+		return
+	}
+	return
+}
+
+// WriteKVv2 writes a new version of a secret to the KV version 2 engine at
+// mount, using a fresh token.
+func (vcc *VaultClientConnection) WriteKVv2(l lane.Lane, mount, path string, data map[string]any) (err error) {
+	return vcc.writeKVv2(l, mount, path, data)
+}
+
+// WriteKVv2CAS writes a secret to the KV version 2 engine at mount only if
+// its current version is cas, for check-and-set updates. A cas of zero
+// writes only if the secret doesn't exist yet.
+func (vcc *VaultClientConnection) WriteKVv2CAS(l lane.Lane, mount, path string, data map[string]any, cas int) (err error) {
+	return vcc.writeKVv2(l, mount, path, data, vaultapi.WithCheckAndSet(cas))
+}
+
+// writeKVv2 is the worker of WriteKVv2 and WriteKVv2CAS; the KV v2 client
+// wraps data in the {"data": ...} envelope and writes to mount/data/path
+func (vcc *VaultClientConnection) writeKVv2(l lane.Lane, mount, path string, data map[string]any, opts ...vaultapi.KVOption) (err error) {
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		_, err = vc.KVv2(mount).Put(l, path, data, opts...)
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: error writing %s/%s: %v", mount, path, err)
+		return
+	}
+	return
+}
+
+// ReadKV reads a secret from the KV engine at mount, whichever version the
+// engine is. The version is probed on first use of the mount and cached.
+func (vcc *VaultClientConnection) ReadKV(l lane.Lane, mount, path string) (data map[string]any, err error) {
+	var secret *vaultapi.KVSecret
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		var version int
+		if version, err = vcc.kvVersion(l, vc, mount); err != nil {
+			return
+		}
+
+		if version == 2 {
+			secret, err = vc.KVv2(mount).Get(l, path)
+		} else {
+			secret, err = vc.KVv1(mount).Get(l, path)
+		}
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: error reading %s/%s: %v", mount, path, err)
+		return
+	}
+
+	data = secret.Data
+	return
+}
+
+// kvVersion provides the KV engine version of mount, asking Vault only if
+// the mount hasn't been seen before
+func (vcc *VaultClientConnection) kvVersion(l lane.Lane, vc *vaultapi.Client, mount string) (version int, err error) {
+	mount = strings.Trim(mount, "/")
+
+	vcc.kvMu.Lock()
+	version, cached := vcc.kvVersions[mount]
+	vcc.kvMu.Unlock()
+	if cached {
+		return
+	}
+
+	var secret *vaultapi.Secret
+	if secret, err = vc.Logical().ReadWithContext(l, "sys/internal/ui/mounts/"+mount); err != nil {
+		l.Errorf("vault client: can't probe mount %s: %v", mount, err)
+		return
+	}
+
+	// KV engines without a version option are version 1
+	version = 1
+	if secret != nil {
+		if options, ok := secret.Data["options"].(map[string]any); ok {
+			if v, _ := options["version"].(string); v == "2" {
+				version = 2
+			}
+		}
+	}
+	l.Tracef("vault client: mount %s is kv v%d", mount, version)
+
+	vcc.kvMu.Lock()
+	if vcc.kvVersions == nil {
+		vcc.kvVersions = map[string]int{}
+	}
+	vcc.kvVersions[mount] = version
+	vcc.kvMu.Unlock()
+	return
+}
@@ -0,0 +1,195 @@
+package vaulttoken
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestReadManyKVv2RecoversRevokedToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "a", `{"v":"1"}`)
+	mv.putSecret("secret", "b", `{"v":"2"}`)
+	vcc := newAppRoleClient(t, l, mv, WithRestartRecovery(1))
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// as after a Vault restart that lost the token
+	mv.revoke(vc.Token())
+
+	secrets, err := vcc.ReadManyKVv2(l, "secret", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if secrets["a"]["v"] != "1" || secrets["b"]["v"] != "2" {
+		t.Errorf("unexpected secrets %v", secrets)
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected 2 logins, got %d", n)
+	}
+}
+
+func TestReadKVv2VersionsRecoversRevokedToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"v":"x"}`)
+	vcc := newAppRoleClient(t, l, mv, WithRestartRecovery(1))
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	mv.revoke(vc.Token())
+
+	// the concurrent reads share one fresh login
+	secrets, err := vcc.ReadKVv2Versions(l, "secret", "app", []int{1, 2, 3})
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	for _, version := range []int{1, 2, 3} {
+		if secrets[version]["v"] != "x" {
+			t.Errorf("version %d: unexpected data %v", version, secrets[version])
+		}
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected 2 logins, got %d", n)
+	}
+}
+
+func TestReadKVDetectsVersion(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("kv2", "app", `{"engine":"v2"}`)
+	mv.putSecretV1("kv1", "app", `{"engine":"v1"}`)
+	vcc := newStaticClient(t, l, mv)
+
+	for range 3 {
+		for _, mount := range []string{"kv1", "kv2"} {
+			data, err := vcc.ReadKV(l, mount, "app")
+			if err != nil {
+				t.Fatalf("%s: read failed: %v", mount, err)
+			}
+			if expected := "v" + mount[2:]; data["engine"] != expected {
+				t.Errorf("%s: expected the %s secret, got %v", mount, expected, data)
+			}
+		}
+	}
+
+	// each mount is probed once
+	if n := mv.mountProbes.Load(); n != 2 {
+		t.Errorf("expected 2 mount probes, got %d", n)
+	}
+}
+
+func TestReadKVv2IfChanged(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "config", `{"mode":"blue"}`)
+	vcc := newStaticClient(t, l, mv)
+
+	// the first poll knows no version, so it reads the data
+	data, version, changed, err := vcc.ReadKVv2IfChanged(l, "secret", "config", 0)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !changed || version != 1 || data["mode"] != "blue" {
+		t.Errorf("expected version 1 with mode blue, got changed %v, version %d, data %v", changed, version, data)
+	}
+
+	// unchanged: only the metadata is read
+	reads := mv.kvReads.Load()
+	data, version, changed, err = vcc.ReadKVv2IfChanged(l, "secret", "config", version)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if changed || version != 1 || data != nil {
+		t.Errorf("expected no change at version 1, got changed %v, version %d, data %v", changed, version, data)
+	}
+	if n := mv.kvReads.Load() - reads; n != 0 {
+		t.Errorf("expected no data read for an unchanged secret, got %d", n)
+	}
+
+	// changed: the new version's data is read
+	mv.putSecret("secret", "config", `{"mode":"green"}`)
+	data, version, changed, err = vcc.ReadKVv2IfChanged(l, "secret", "config", version)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if !changed || version != 2 || data["mode"] != "green" {
+		t.Errorf("expected version 2 with mode green, got changed %v, version %d, data %v", changed, version, data)
+	}
+}
+
+func TestReadKVv2IfChangedMissing(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	if _, _, changed, err := vcc.ReadKVv2IfChanged(l, "secret", "nothing", 0); err == nil || changed {
+		t.Errorf("expected a missing secret to fail, got changed %v, err %v", changed, err)
+	}
+}
+
+func TestWriteKVv2(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if err := vcc.WriteKVv2(l, "secret", "app", map[string]any{"password": "hunter2"}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	// the payload is the data of the envelope written to mount/data/path
+	mv.mu.Lock()
+	stored := mv.secrets["secret/data/app"]
+	mv.mu.Unlock()
+	if stored != `{"password":"hunter2"}` {
+		t.Errorf("unexpected stored data %s", stored)
+	}
+
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+}
+
+func TestWriteKVv2CAS(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	// a cas of zero creates a secret
+	if err := vcc.WriteKVv2CAS(l, "secret", "app", map[string]any{"mode": "blue"}, 0); err != nil {
+		t.Fatalf("create failed: %v", err)
+	}
+
+	// but doesn't overwrite one, nor does a stale version
+	for _, cas := range []int{0, 2} {
+		if err := vcc.WriteKVv2CAS(l, "secret", "app", map[string]any{"mode": "red"}, cas); err == nil {
+			t.Errorf("cas %d: expected a check-and-set mismatch", cas)
+		}
+	}
+
+	// the current version does
+	if err := vcc.WriteKVv2CAS(l, "secret", "app", map[string]any{"mode": "green"}, 1); err != nil {
+		t.Fatalf("update failed: %v", err)
+	}
+	data, version, _, err := vcc.ReadKVv2IfChanged(l, "secret", "app", 0)
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if version != 2 || data["mode"] != "green" {
+		t.Errorf("expected version 2 with mode green, got version %d, data %v", version, data)
+	}
+	if n := mv.writes.Load(); n != 2 {
+		t.Errorf("expected 2 writes, got %d", n)
+	}
+}
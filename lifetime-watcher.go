@@ -0,0 +1,193 @@
+package vaulttoken
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// tokenWatcher is the part of vaultapi.LifetimeWatcher that the
+	// connection uses
+	tokenWatcher interface {
+		Start()
+		Stop()
+		RenewCh() <-chan *vaultapi.RenewOutput
+		DoneCh() <-chan error
+	}
+
+	// watcherFactory makes the lifetime watcher of a login token
+	watcherFactory func(client *vaultapi.Client, token *vaultapi.Secret) (tokenWatcher, error)
+)
+
+// newVaultWatcher makes a Vault LifetimeWatcher, the default watcherFactory
+func newVaultWatcher(client *vaultapi.Client, token *vaultapi.Secret) (tokenWatcher, error) {
+	return client.NewLifetimeWatcher(&vaultapi.LifetimeWatcherInput{Secret: token})
+}
+
+// withWatcherFactory replaces how StartLifetimeWatcher makes its watchers
+func withWatcherFactory(factory watcherFactory) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.newWatcher = factory
+	}
+}
+
+// StartLifetimeWatcher keeps the login token alive with Vault's
+// LifetimeWatcher, which renews the token as it nears expiration. Renewals
+// are published as TokenRenewed events. When the watcher gives up (the token
+// reached its max TTL, or renewal failed), a TokenRenewFailed event is
+// published, a fresh login is made, and the new token is watched.
+//
+// Any background renewer already running is stopped first.
+//
+// The watcher runs until stop is called, the lane's context ends, or the
+// connection is shut down. It requires a login-based connection; a static
+// token can't be renewed by login.
+func (vcc *VaultClientConnection) StartLifetimeWatcher(l lane.Lane) (stop func(), err error) {
+	if vcc.auth == nil {
+		err = fmt.Errorf("%w: the lifetime watcher requires a login-based connection", ErrNoAuthConfigured)
+		return
+	}
+
+	var token *vaultapi.Secret
+	if _, token, err = vcc.getApiInterface(l); err != nil {
+		return
+	}
+
+	// replace any running renewer rather than compete with it
+	vcc.renewStartMu.Lock()
+	defer vcc.renewStartMu.Unlock()
+	vcc.stopRenewer()
+
+	stopCh := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		vcc.watchLifetime(l, token, stopCh)
+	}()
+
+	stop = vcc.setRenewer(stopCh, finished, false)
+	return
+}
+
+// watchLifetime runs lifetime watchers for successive login tokens
+func (vcc *VaultClientConnection) watchLifetime(l lane.Lane, token *vaultapi.Secret, stopCh chan struct{}) {
+	for {
+		watcher, err := vcc.opts.newWatcher(vcc.vc, token)
+		if err != nil {
+			l.Errorf("vault client: can't create token lifetime watcher: %v", err)
+			vcc.publishTokenEvent(TokenRenewFailed, 0, err)
+			return
+		}
+		go watcher.Start()
+
+		next, ok := vcc.awaitWatcher(l, token, watcher, stopCh)
+		if !ok {
+			return
+		}
+
+		if next == nil {
+			if next = vcc.reloginUntilStopped(l, stopCh); next == nil {
+				return
+			}
+		}
+		token = next
+	}
+}
+
+// awaitWatcher relays the watcher's renewals of token until it finishes,
+// returning false if the caller asked to stop instead. If another login
+// replaced token meanwhile, the new login is returned to be watched in its
+// place; otherwise next is nil and a fresh login is needed.
+func (vcc *VaultClientConnection) awaitWatcher(l lane.Lane, token *vaultapi.Secret, watcher tokenWatcher, stopCh chan struct{}) (next *vaultapi.Secret, ok bool) {
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-stopCh:
+			return
+
+		case <-l.Done():
+			return
+
+		case renewal := <-watcher.RenewCh():
+			var ttl time.Duration
+			if renewal.Secret != nil && renewal.Secret.Auth != nil {
+				ttl = time.Duration(renewal.Secret.Auth.LeaseDuration) * time.Second
+			}
+
+			var current bool
+			if current, next = vcc.applyRenewal(token, renewal.Secret, ttl); !current {
+				l.Debugf("vault client: watched token was replaced by a newer login")
+				return next, true
+			}
+
+			l.Tracef("vault client: token renewed by lifetime watcher, ttl %s", ttl)
+			vcc.stats.refreshes.Add(1)
+			vcc.publishTokenEvent(TokenRenewed, ttl, nil)
+
+		case err := <-watcher.DoneCh():
+			if err == nil {
+				err = errors.New("token reached its renewal limit")
+			}
+			l.Infof("vault client: token lifetime watcher finished: %v", err)
+			vcc.stats.recordError(err)
+			vcc.publishTokenEvent(TokenRenewFailed, 0, err)
+			return nil, true
+		}
+	}
+}
+
+// applyRenewal feeds a watcher's renewal of token back into the connection,
+// so the provider's expiration and the last login follow the renewed TTL.
+// If token is no longer the current login, nothing is changed and the
+// current login, if any, is returned instead.
+func (vcc *VaultClientConnection) applyRenewal(token, renewal *vaultapi.Secret, ttl time.Duration) (current bool, latest *vaultapi.Secret) {
+	vcc.loginMu.Lock()
+	defer vcc.loginMu.Unlock()
+
+	latest = vcc.lastLogin
+	if latest == nil || vcc.provider == nil || latest.Auth.ClientToken != token.Auth.ClientToken {
+		return
+	}
+
+	vcc.provider.renewed(ttl)
+	if renewal != nil && renewal.Auth != nil && renewal.Auth.ClientToken == token.Auth.ClientToken {
+		vcc.lastLogin = renewal
+	}
+	return true, nil
+}
+
+// reloginUntilStopped logs in with backoff until it succeeds, the caller
+// asks to stop, or the error isn't retryable, in which case nil is returned
+func (vcc *VaultClientConnection) reloginUntilStopped(l lane.Lane, stopCh chan struct{}) *vaultapi.Secret {
+	b := backoff.NewExponentialBackOff()
+	b.MaxElapsedTime = 0
+
+	for {
+		token, err := vcc.relogin(l)
+		if err == nil {
+			return token
+		}
+		if !vcc.opts.retryable(err) {
+			l.Errorf("vault client: re-login failed and won't be retried: %v", err)
+			vcc.stats.recordError(err)
+			return nil
+		}
+
+		delay := b.NextBackOff()
+		l.Warnf("vault client: re-login failed, retrying in %s: %v", delay, err)
+
+		select {
+		case <-stopCh:
+			return nil
+		case <-l.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
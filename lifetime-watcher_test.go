@@ -0,0 +1,170 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// fakeWatcher is a lifetime watcher driven by the test
+	fakeWatcher struct {
+		token   *vaultapi.Secret
+		renewCh chan *vaultapi.RenewOutput
+		doneCh  chan error
+		stopped chan struct{}
+	}
+)
+
+func (fw *fakeWatcher) Start() {
+}
+
+func (fw *fakeWatcher) Stop() {
+	close(fw.stopped)
+}
+
+func (fw *fakeWatcher) RenewCh() <-chan *vaultapi.RenewOutput {
+	return fw.renewCh
+}
+
+func (fw *fakeWatcher) DoneCh() <-chan error {
+	return fw.doneCh
+}
+
+// fakeWatcherFactory provides each watcher the connection makes on a channel
+func fakeWatcherFactory(watchers chan *fakeWatcher) watcherFactory {
+	return func(client *vaultapi.Client, token *vaultapi.Secret) (tokenWatcher, error) {
+		fw := &fakeWatcher{
+			token:   token,
+			renewCh: make(chan *vaultapi.RenewOutput),
+			doneCh:  make(chan error),
+			stopped: make(chan struct{}),
+		}
+		watchers <- fw
+		return fw, nil
+	}
+}
+
+func nextWatcher(t *testing.T, watchers chan *fakeWatcher) *fakeWatcher {
+	t.Helper()
+	select {
+	case fw := <-watchers:
+		return fw
+	case <-time.After(2 * time.Second):
+		t.Fatal("no watcher was made")
+		return nil
+	}
+}
+
+func nextEvent(t *testing.T, events <-chan TokenEvent) TokenEvent {
+	t.Helper()
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(2 * time.Second):
+		t.Fatal("no token event")
+		return TokenEvent{}
+	}
+}
+
+func TestLifetimeWatcherRenewal(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	watchers := make(chan *fakeWatcher, 1)
+	vcc := newAppRoleClient(t, l, mv, withWatcherFactory(fakeWatcherFactory(watchers)))
+
+	stop, err := vcc.StartLifetimeWatcher(l)
+	if err != nil {
+		t.Fatalf("can't start the watcher: %v", err)
+	}
+	defer stop()
+
+	fw := nextWatcher(t, watchers)
+	if fw.token.Auth.ClientToken != "tok-1" {
+		t.Errorf("watching the wrong token %s", fw.token.Auth.ClientToken)
+	}
+
+	events, unsubscribe := vcc.SubscribeTokenEvents(4)
+	defer unsubscribe()
+
+	// a renewal to 2h is fed back to the connection's token
+	fw.renewCh <- &vaultapi.RenewOutput{
+		RenewedAt: time.Now(),
+		Secret:    &vaultapi.Secret{Auth: &vaultapi.SecretAuth{ClientToken: "tok-1", LeaseDuration: 7200, Renewable: true}},
+	}
+	ev := nextEvent(t, events)
+	if ev.Kind != TokenRenewed || ev.TTL != 2*time.Hour {
+		t.Errorf("expected a 2h renewal event, got %v %v", ev.Kind, ev.TTL)
+	}
+
+	provider, err := vcc.liveProvider()
+	if err != nil {
+		t.Fatalf("no provider: %v", err)
+	}
+	if remaining := time.Until(provider.expiresAt()); remaining < 119*time.Minute {
+		t.Errorf("the renewal didn't extend the token, %v remaining", remaining)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestLifetimeWatcherDone(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	watchers := make(chan *fakeWatcher, 1)
+	vcc := newAppRoleClient(t, l, mv, withWatcherFactory(fakeWatcherFactory(watchers)))
+
+	stop, err := vcc.StartLifetimeWatcher(l)
+	if err != nil {
+		t.Fatalf("can't start the watcher: %v", err)
+	}
+	defer stop()
+
+	fw := nextWatcher(t, watchers)
+	events, unsubscribe := vcc.SubscribeTokenEvents(4)
+	defer unsubscribe()
+
+	// the watcher giving up is reported, and a fresh login is watched
+	fw.doneCh <- errors.New("max ttl reached")
+	if ev := nextEvent(t, events); ev.Kind != TokenRenewFailed {
+		t.Errorf("expected a renew-failed event, got %v", ev.Kind)
+	}
+	if ev := nextEvent(t, events); ev.Kind != TokenLoggedIn {
+		t.Errorf("expected a login event, got %v", ev.Kind)
+	}
+
+	next := nextWatcher(t, watchers)
+	if next.token.Auth.ClientToken != "tok-2" {
+		t.Errorf("expected the new token to be watched, got %s", next.token.Auth.ClientToken)
+	}
+	select {
+	case <-fw.stopped:
+	case <-time.After(time.Second):
+		t.Error("the finished watcher wasn't stopped")
+	}
+}
+
+func TestLifetimeWatcherStop(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	watchers := make(chan *fakeWatcher, 1)
+	vcc := newAppRoleClient(t, l, mv, withWatcherFactory(fakeWatcherFactory(watchers)))
+
+	stop, err := vcc.StartLifetimeWatcher(l)
+	if err != nil {
+		t.Fatalf("can't start the watcher: %v", err)
+	}
+	fw := nextWatcher(t, watchers)
+
+	stop()
+	select {
+	case <-fw.stopped:
+	case <-time.After(time.Second):
+		t.Error("the watcher wasn't stopped")
+	}
+}
@@ -0,0 +1,36 @@
+package vaulttoken
+
+import (
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// Read reads path from Vault's logical backend with a fresh token, logging
+// in again first if the current token has expired or been revoked. A path
+// with no secret gives a nil secret and no error.
+func (vcc *VaultClientConnection) Read(l lane.Lane, path string) (secret *vaultapi.Secret, err error) {
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		secret, err = vc.Logical().ReadWithContext(l, path)
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: error reading %s: %v", path, err)
+		return
+	}
+	return
+}
+
+// Write writes data to path in Vault's logical backend with a fresh token,
+// logging in again first if the current token has expired or been revoked.
+// The response secret is nil for endpoints that return no data.
+func (vcc *VaultClientConnection) Write(l lane.Lane, path string, data map[string]any) (secret *vaultapi.Secret, err error) {
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		secret, err = vc.Logical().WriteWithContext(l, path, data)
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: error writing %s: %v", path, err)
+		return
+	}
+	return
+}
@@ -0,0 +1,62 @@
+package vaulttoken
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestReadLogsInAgainAfterExpiry(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	if _, err := vcc.Read(l, "secret/data/app"); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+
+	// the first call after the token expires logs in again on its own
+	fc.advance(fc.Now().Add(2 * time.Hour))
+	secret, err := vcc.Read(l, "secret/data/app")
+	if err != nil {
+		t.Fatalf("read with an expired token failed: %v", err)
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected a second login, got %d", n)
+	}
+	data, _ := secret.Data["data"].(map[string]any)
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", secret.Data)
+	}
+}
+
+func TestWriteLogsInAgainAfterExpiry(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	if _, err := vcc.Write(l, "secret/data/app", map[string]any{"data": map[string]any{"mode": "blue"}}); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+
+	fc.advance(fc.Now().Add(2 * time.Hour))
+	secret, err := vcc.Write(l, "secret/data/app", map[string]any{"data": map[string]any{"mode": "green"}})
+	if err != nil {
+		t.Fatalf("write with an expired token failed: %v", err)
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected a second login, got %d", n)
+	}
+	if version, _ := secret.Data["version"].(json.Number); version != "2" {
+		t.Errorf("expected version 2, got %v", secret.Data["version"])
+	}
+}
@@ -0,0 +1,51 @@
+package vaulttoken
+
+import (
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// LoginRequestInspector is shown each login request before it is sent,
+	// for auditing. Credentials in body are redacted.
+	LoginRequestInspector func(path string, body map[string]any)
+
+	// inspectingTransport shows login requests to an inspector
+	inspectingTransport struct {
+		VaultTransport
+		inspect LoginRequestInspector
+	}
+)
+
+const kRedacted = "[redacted]"
+
+// login body fields that carry credentials
+var redactedLoginFields = []string{"jwt", "secret_id", "password"}
+
+// WithLoginRequestInspector shows inspect the path and body of every login
+// request before it is sent to Vault. The jwt, secret_id and password fields
+// are redacted; the request itself is sent unchanged.
+func WithLoginRequestInspector(inspect LoginRequestInspector) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.inspector = inspect
+	}
+}
+
+func (t inspectingTransport) Login(l lane.Lane, client *vaultapi.Client, loginPath string, data map[string]any) (*vaultapi.Secret, error) {
+	t.inspect(loginPath, redactLoginBody(data))
+	return t.VaultTransport.Login(l, client, loginPath, data)
+}
+
+// redactLoginBody copies body with the credentials replaced
+func redactLoginBody(body map[string]any) map[string]any {
+	redacted := make(map[string]any, len(body))
+	for k, v := range body {
+		redacted[k] = v
+	}
+	for _, field := range redactedLoginFields {
+		if _, has := redacted[field]; has {
+			redacted[field] = kRedacted
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,52 @@
+package vaulttoken
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestRedactLoginBody(t *testing.T) {
+	body := map[string]any{"role": "r", "jwt": "j", "secret_id": "s", "password": "p"}
+	redacted := redactLoginBody(body)
+	for _, field := range redactedLoginFields {
+		if redacted[field] != kRedacted {
+			t.Errorf("%s not redacted: %v", field, redacted[field])
+		}
+	}
+	if redacted["role"] != "r" {
+		t.Errorf("role changed: %v", redacted["role"])
+	}
+	if body["jwt"] != "j" {
+		t.Error("the original body was changed")
+	}
+}
+
+func TestLoginRequestInspector(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	var inspectedPath string
+	var inspectedBody map[string]any
+	vcc := newAppRoleClient(t, l, mv, WithLoginRequestInspector(func(path string, body map[string]any) {
+		inspectedPath = path
+		inspectedBody = body
+	}))
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// the inspector sees the redacted request
+	if inspectedPath != "auth/approle/login" {
+		t.Errorf("unexpected inspected path %s", inspectedPath)
+	}
+	if inspectedBody["role_id"] != "role-id" || inspectedBody["secret_id"] != kRedacted {
+		t.Errorf("unexpected inspected body %v", inspectedBody)
+	}
+
+	// vault gets the real one
+	if body := mv.lastLoginBody(); body["secret_id"] != "secret-id" {
+		t.Errorf("unexpected login body %v", body)
+	}
+}
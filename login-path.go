@@ -0,0 +1,65 @@
+package vaulttoken
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Login path templates. Each auth backend declares the shape of its login
+// path, and expandLoginPath fills in the placeholders.
+const (
+	// kLoginPathTemplate is the common auth/<mount>/login shape
+	kLoginPathTemplate = "{authPath}/login"
+	// LoginPathNamedTemplate is the auth/<mount>/login/<name> shape used by
+	// backends such as userpass and ldap, for WithLoginPathTemplate
+	LoginPathNamedTemplate = "{authPath}/login/{name}"
+)
+
+// WithLoginPathTemplate replaces the shape of the login path used by the
+// AppRole, Kubernetes, JWT and GCP logins, e.g., for an auth method behind a
+// gateway with its own path layout. The template must contain {authPath},
+// the auth mount; {name} is replaced by the Vault role. The default is
+// "{authPath}/login".
+func WithLoginPathTemplate(template string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.loginTemplate = template
+	}
+}
+
+// expandLoginPath fills the {authPath} and {name} placeholders of a login
+// path template
+func expandLoginPath(template, authPath, name string) string {
+	return strings.NewReplacer(
+		"{authPath}", strings.TrimSuffix(authPath, "/"),
+		"{name}", name,
+	).Replace(template)
+}
+
+// validateLoginTemplate rejects a login path template without the auth
+// mount, or with a placeholder expandLoginPath doesn't fill
+func validateLoginTemplate(template string) error {
+	if !strings.Contains(template, "{authPath}") {
+		return fmt.Errorf("login path template %q must contain {authPath}", template)
+	}
+	expanded := expandLoginPath(template, "auth/x", "name")
+	if strings.ContainsAny(expanded, "{}") {
+		return fmt.Errorf("login path template %q has an unknown placeholder", template)
+	}
+	if strings.HasPrefix(template, "/") {
+		return fmt.Errorf("login path template %q must not start with /", template)
+	}
+	return nil
+}
+
+// validateAuthPath rejects an auth mount path that can't produce a sensible
+// login endpoint, which would otherwise surface as a confusing 404
+func validateAuthPath(authPath string) error {
+	if authPath == "" {
+		return fmt.Errorf("auth path is empty")
+	}
+	name, found := strings.CutPrefix(authPath, "auth/")
+	if !found || strings.Trim(name, "/") == "" {
+		return fmt.Errorf("auth path %q must be of the form auth/<mount>", authPath)
+	}
+	return nil
+}
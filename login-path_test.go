@@ -0,0 +1,159 @@
+package vaulttoken
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestExpandLoginPath(t *testing.T) {
+	cases := []struct {
+		template, authPath, name, expected string
+	}{
+		{kLoginPathTemplate, "auth/approle", "role", "auth/approle/login"},
+		{kLoginPathTemplate, "auth/approle/", "role", "auth/approle/login"},
+		{LoginPathNamedTemplate, "auth/userpass", "alice", "auth/userpass/login/alice"},
+		{"{authPath}/signin", "auth/gw", "role", "auth/gw/signin"},
+	}
+	for _, c := range cases {
+		if path := expandLoginPath(c.template, c.authPath, c.name); path != c.expected {
+			t.Errorf("expandLoginPath(%q, %q, %q) = %q, expected %q", c.template, c.authPath, c.name, path, c.expected)
+		}
+	}
+}
+
+func TestValidateLoginTemplate(t *testing.T) {
+	for _, template := range []string{kLoginPathTemplate, LoginPathNamedTemplate, "{authPath}/signin"} {
+		if err := validateLoginTemplate(template); err != nil {
+			t.Errorf("template %q rejected: %v", template, err)
+		}
+	}
+	for _, template := range []string{"", "auth/x/login", "/{authPath}/login", "{authPath}/login/{role}"} {
+		if err := validateLoginTemplate(template); err == nil {
+			t.Errorf("template %q accepted", template)
+		}
+	}
+}
+
+func TestLoginPathTemplateAppRole(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	vcc := newAppRoleClient(t, l, mv, WithLoginPathTemplate(LoginPathNamedTemplate))
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if path := mv.lastLoginPath(); path != "auth/approle/login/role-id" {
+		t.Errorf("unexpected login path %s", path)
+	}
+}
+
+func TestLoginPathTemplateJwt(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	source := func() (string, error) { return "header.payload.signature", nil }
+	vcc, err := NewVaultClientJwt(l, mv.srv.URL, "", "", "ci", source, WithJwtAuthPath("auth/oidc"), WithLoginPathTemplate("{authPath}/login/{name}"))
+	if err != nil {
+		t.Fatalf("can't make jwt client: %v", err)
+	}
+	if _, err = vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if path := mv.lastLoginPath(); path != "auth/oidc/login/ci" {
+		t.Errorf("unexpected login path %s", path)
+	}
+}
+
+func TestLoginPathTemplateDefault(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	vcc := newAppRoleClient(t, l, mv)
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if path := mv.lastLoginPath(); path != "auth/approle/login" {
+		t.Errorf("unexpected login path %s", path)
+	}
+}
+
+func TestLoginPathTemplateInvalid(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	if _, err := NewVaultClientAppRole(l, mv.srv.URL, "", "", "role-id", "secret-id", WithLoginPathTemplate("auth/approle/login")); err == nil {
+		t.Error("expected a template without {authPath} to be rejected")
+	}
+}
+
+func TestValidateAuthPath(t *testing.T) {
+	for _, authPath := range []string{"auth/approle", "auth/gcp-prod", "auth/team/k8s", "auth/jwt/"} {
+		if err := validateAuthPath(authPath); err != nil {
+			t.Errorf("auth path %q rejected: %v", authPath, err)
+		}
+	}
+	for _, authPath := range []string{"", "approle", "/auth/approle", "auth/", "auth//", "secret/approle"} {
+		if err := validateAuthPath(authPath); err == nil {
+			t.Errorf("auth path %q accepted", authPath)
+		}
+	}
+}
+
+func TestConstructionAuthPath(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("k8s.jwt.value"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	source := func() (string, error) { return "header.payload.signature", nil }
+	connects := []struct {
+		method  string
+		connect func(l lane.Lane, mv *mockVault, authPath string) (*VaultClientConnection, error)
+		login   string // login path of the default mount
+	}{
+		{AuthMethodAppRole, func(l lane.Lane, mv *mockVault, authPath string) (*VaultClientConnection, error) {
+			return NewVaultClientAppRole(l, mv.srv.URL, "", "", "role-id", "secret-id", WithAppRoleAuthPath(authPath))
+		}, "auth/approle/login"},
+		{AuthMethodKubernetes, func(l lane.Lane, mv *mockVault, authPath string) (*VaultClientConnection, error) {
+			return NewVaultClientKubernetes(l, mv.srv.URL, "", "", "my-role", WithKubernetesAuthPath(authPath), WithKubernetesTokenFile(tokenFile))
+		}, "auth/kubernetes/login"},
+		{AuthMethodJwt, func(l lane.Lane, mv *mockVault, authPath string) (*VaultClientConnection, error) {
+			return NewVaultClientJwt(l, mv.srv.URL, "", "", "ci", source, WithJwtAuthPath(authPath))
+		}, "auth/jwt/login"},
+	}
+	for _, c := range connects {
+		// an empty path leaves the default mount in place
+		for _, authPath := range []string{"approle/", "/auth/approle", "auth/"} {
+			t.Run(c.method+" "+authPath, func(t *testing.T) {
+				l := lane.NewTestingLane(context.Background())
+				mv := newMockVault(t)
+
+				if _, err := c.connect(l, mv, authPath); err == nil {
+					t.Fatal("expected construction to fail")
+				}
+				if path := mv.lastLoginPath(); path != "" {
+					t.Errorf("a login was attempted at %q", path)
+				}
+			})
+		}
+
+		t.Run(c.method+" empty", func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+
+			vcc, err := c.connect(l, mv, "")
+			if err != nil {
+				t.Fatalf("can't connect: %v", err)
+			}
+			if _, err = vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			if path := mv.lastLoginPath(); path != c.login {
+				t.Errorf("expected a login at %q, got %q", c.login, path)
+			}
+		})
+	}
+}
@@ -0,0 +1,654 @@
+package vaulttoken
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// mockVault is an in-process Vault server covering the login, token
+	// and KV v2 endpoints used by this package
+	mockVault struct {
+		srv *httptest.Server
+
+		logins       atomic.Int32
+		renewals     atomic.Int32
+		revocations  atomic.Int32
+		failedLogins atomic.Int32
+		lookups      atomic.Int32
+		mountProbes  atomic.Int32
+		healthChecks atomic.Int32
+		kvReads      atomic.Int32 // KV data reads, not counting metadata
+		writes       atomic.Int32
+		inFlight     atomic.Int32
+		peakInFlight atomic.Int32
+
+		mu             sync.Mutex
+		tokens         map[string]bool // issued tokens; false once revoked
+		secrets        map[string]string
+		versions       map[string]int  // current version of each KV v2 secret
+		kvV1Mounts     map[string]bool // KV mounts that are version 1
+		ttl            int
+		explicitMaxTtl int
+		loginGate      chan struct{}
+		renewGate      chan struct{}
+		readGate       chan struct{} // holds KV reads until closed
+		revokeGate     chan struct{} // hangs revocations until closed
+		rejectLogins   bool
+		slowBodies     bool   // login and renew bodies arrive in two pieces
+		loginErrors    []int  // statuses of failed logins before one succeeds
+		readErrors     []int  // statuses of failed KV reads before one succeeds
+		retryAfter     string // Retry-After header of a failed login
+		bareDenials    bool   // an invalid token gets only "permission denied"
+		rootTokens     bool   // lookups describe root tokens
+		nonRenewable   bool   // issued tokens can't be renewed
+		failRevokes    bool
+		loginPaths     []string
+		loginBodies    []map[string]any
+		createBodies   []map[string]any    // bodies of child token creations
+		readIndexes    []string            // X-Vault-Index presented on each KV read
+		wrappings      map[string]string   // wrapping token to its creation path
+		oidcRoles      map[string]bool     // roles that issue identity tokens
+		tunings        map[string][2]int   // mount to its default and max lease ttls
+		groupNames     []string            // groups of the tokens' entity; no entity if nil
+		lastNamespace  string              // X-Vault-Namespace of the latest request
+		noSudo         bool                // tokens lack sudo, e.g., to list accessors
+		clusterId      string              // reported by sys/health
+		gcpRoles       map[string][]string // GCP auth role to its bound_audiences
+		license        string              // sys/license/status data; not enterprise if empty
+	}
+)
+
+// newMockVault starts a mock Vault server that is stopped at test cleanup
+func newMockVault(t testing.TB) *mockVault {
+	mv := &mockVault{
+		tokens:  map[string]bool{},
+		secrets: map[string]string{},
+		ttl:     3600,
+	}
+	mv.srv = httptest.NewServer(http.HandlerFunc(mv.serve))
+	t.Cleanup(mv.srv.Close)
+	return mv
+}
+
+// newMockVaultTLS starts a mock Vault server over https, letting setup
+// adjust the server (e.g., its TLS config) before it starts
+func newMockVaultTLS(t testing.TB, setup func(srv *httptest.Server)) *mockVault {
+	mv := &mockVault{
+		tokens:  map[string]bool{},
+		secrets: map[string]string{},
+		ttl:     3600,
+	}
+	mv.srv = httptest.NewUnstartedServer(http.HandlerFunc(mv.serve))
+	if setup != nil {
+		setup(mv.srv)
+	}
+	mv.srv.StartTLS()
+	t.Cleanup(mv.srv.Close)
+	return mv
+}
+
+// caPem is the server's self-signed cert in PEM form, for WithCACertPEM
+func (mv *mockVault) caPem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mv.srv.Certificate().Raw})
+}
+
+// addToken makes the server accept a token it didn't issue, e.g., a static
+// token
+func (mv *mockVault) addToken(token string) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	mv.tokens[token] = true
+}
+
+// putSecret stores a KV v2 secret at mount/path, given as raw JSON so
+// number formatting is exact
+func (mv *mockVault) putSecret(mount, path, dataJson string) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	mv.secrets[mount+"/data/"+path] = dataJson
+	mv.bumpVersion(mount + "/data/" + path)
+}
+
+// bumpVersion advances the version of the KV v2 secret at dataPath,
+// providing the new version; the caller holds mu
+func (mv *mockVault) bumpVersion(dataPath string) int {
+	if mv.versions == nil {
+		mv.versions = map[string]int{}
+	}
+	mv.versions[dataPath]++
+	return mv.versions[dataPath]
+}
+
+// putSecretV1 stores a secret at mount/path of a KV v1 engine, given as raw
+// JSON
+func (mv *mockVault) putSecretV1(mount, path, dataJson string) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if mv.kvV1Mounts == nil {
+		mv.kvV1Mounts = map[string]bool{}
+	}
+	mv.kvV1Mounts[mount] = true
+	mv.secrets[mount+"/"+path] = dataJson
+}
+
+// lastLoginPath provides the path of the most recent login request
+func (mv *mockVault) lastLoginPath() string {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if len(mv.loginPaths) == 0 {
+		return ""
+	}
+	return mv.loginPaths[len(mv.loginPaths)-1]
+}
+
+// lastLoginBody provides the decoded body of the most recent login request
+func (mv *mockVault) lastLoginBody() map[string]any {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if len(mv.loginBodies) == 0 {
+		return nil
+	}
+	return mv.loginBodies[len(mv.loginBodies)-1]
+}
+
+// addWrapping makes the server know a response-wrapping token created by a
+// request to creationPath
+func (mv *mockVault) addWrapping(token, creationPath string) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if mv.wrappings == nil {
+		mv.wrappings = map[string]string{}
+	}
+	mv.wrappings[token] = creationPath
+}
+
+// addOidcRole makes the server issue identity tokens for role
+func (mv *mockVault) addOidcRole(role string) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if mv.oidcRoles == nil {
+		mv.oidcRoles = map[string]bool{}
+	}
+	mv.oidcRoles[role] = true
+}
+
+// addGcpRole makes auth/gcp/role/<role> readable, bound to audiences
+func (mv *mockVault) addGcpRole(role string, audiences ...string) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if mv.gcpRoles == nil {
+		mv.gcpRoles = map[string][]string{}
+	}
+	mv.gcpRoles[role] = audiences
+}
+
+// tuneMount sets the default and max lease ttls reported for mount
+func (mv *mockVault) tuneMount(mount string, defaultTtl, maxTtl int) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if mv.tunings == nil {
+		mv.tunings = map[string][2]int{}
+	}
+	mv.tunings[mount] = [2]int{defaultTtl, maxTtl}
+}
+
+// revoke invalidates a token behind the client's back, e.g., as an operator
+// would
+func (mv *mockVault) revoke(token string) {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	mv.tokens[token] = false
+}
+
+// isRevoked indicates if an issued token was revoked
+func (mv *mockVault) isRevoked(token string) bool {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	valid, issued := mv.tokens[token]
+	return issued && !valid
+}
+
+func (mv *mockVault) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+	n := mv.inFlight.Add(1)
+	defer mv.inFlight.Add(-1)
+	for {
+		peak := mv.peakInFlight.Load()
+		if n <= peak || mv.peakInFlight.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	mv.mu.Lock()
+	mv.lastNamespace = r.Header.Get("X-Vault-Namespace")
+	mv.mu.Unlock()
+
+	if strings.HasSuffix(path, "/login") || strings.Contains(path, "/login/") {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mv.mu.Lock()
+		mv.loginPaths = append(mv.loginPaths, path)
+		mv.loginBodies = append(mv.loginBodies, body)
+		mv.mu.Unlock()
+		mv.login(w, r)
+		return
+	}
+
+	if path == "sys/health" {
+		// unauthenticated
+		mv.healthChecks.Add(1)
+		mv.mu.Lock()
+		clusterId := mv.clusterId
+		mv.mu.Unlock()
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"initialized":true,"sealed":false,"standby":false,"version":"1.15.0","cluster_name":"vault-cluster-mock","cluster_id":%q}`, clusterId))
+		return
+	}
+
+	token := r.Header.Get("X-Vault-Token")
+	mv.mu.Lock()
+	valid := mv.tokens[token]
+	mv.mu.Unlock()
+	if !valid {
+		mv.mu.Lock()
+		bare := mv.bareDenials
+		mv.mu.Unlock()
+		if bare {
+			writeJson(w, http.StatusForbidden, `{"errors":["permission denied"]}`)
+			return
+		}
+		// as Vault 1.10 and later report it
+		writeJson(w, http.StatusForbidden, `{"errors":["2 errors occurred:\n\t* permission denied\n\t* invalid token\n\n"]}`)
+		return
+	}
+
+	if strings.HasPrefix(path, "denied/") {
+		// a path the token's policies don't allow
+		writeJson(w, http.StatusForbidden, `{"errors":["1 error occurred:\n\t* permission denied\n\n"]}`)
+		return
+	}
+
+	if mount, found := strings.CutPrefix(path, "sys/internal/ui/mounts/"); found {
+		mv.mountProbes.Add(1)
+		mv.mu.Lock()
+		v1 := mv.kvV1Mounts[mount]
+		mv.mu.Unlock()
+		if v1 {
+			writeJson(w, http.StatusOK, `{"data":{"type":"kv","path":"`+mount+`/","options":null}}`)
+		} else {
+			writeJson(w, http.StatusOK, `{"data":{"type":"kv","path":"`+mount+`/","options":{"version":"2"}}}`)
+		}
+		return
+	}
+
+	if rest, found := strings.CutPrefix(path, "sys/mounts/"); found && strings.HasSuffix(rest, "/tune") {
+		mount := strings.TrimSuffix(rest, "/tune")
+		mv.mu.Lock()
+		ttls, known := mv.tunings[mount]
+		mv.mu.Unlock()
+		if !known {
+			writeJson(w, http.StatusBadRequest, fmt.Sprintf(`{"errors":["cannot fetch sysview for path %q"]}`, mount+"/"))
+			return
+		}
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"default_lease_ttl":%d,"max_lease_ttl":%d,"force_no_cache":false}}`, ttls[0], ttls[1]))
+		return
+	}
+
+	if path == "identity/entity/id/entity-1" {
+		mv.mu.Lock()
+		groupIds := make([]string, len(mv.groupNames))
+		for i := range mv.groupNames {
+			groupIds[i] = fmt.Sprintf("group-%d", i+1)
+		}
+		mv.mu.Unlock()
+		ids, _ := json.Marshal(groupIds)
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"id":"entity-1","name":"entity-1","group_ids":%s}}`, ids))
+		return
+	}
+
+	if groupId, found := strings.CutPrefix(path, "identity/group/id/group-"); found {
+		var n int
+		fmt.Sscan(groupId, &n)
+		mv.mu.Lock()
+		var name string
+		if n >= 1 && n <= len(mv.groupNames) {
+			name = mv.groupNames[n-1]
+		}
+		mv.mu.Unlock()
+		if name == "" {
+			writeJson(w, http.StatusNotFound, `{"errors":[]}`)
+			return
+		}
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"id":"group-%d","name":%q}}`, n, name))
+		return
+	}
+
+	if path == "sys/license/status" {
+		mv.mu.Lock()
+		license := mv.license
+		mv.mu.Unlock()
+		if license == "" {
+			// as the open source server reports it
+			writeJson(w, http.StatusNotFound, `{"errors":["1 error occurred:\n\t* unsupported path\n\n"]}`)
+			return
+		}
+		writeJson(w, http.StatusOK, `{"data":`+license+`}`)
+		return
+	}
+
+	if role, found := strings.CutPrefix(path, "auth/gcp/role/"); found {
+		mv.mu.Lock()
+		audiences, known := mv.gcpRoles[role]
+		mv.mu.Unlock()
+		if !known {
+			writeJson(w, http.StatusNotFound, `{"errors":[]}`)
+			return
+		}
+		bound, _ := json.Marshal(audiences)
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"role_type":"iam","bound_audiences":%s}}`, bound))
+		return
+	}
+
+	if role, found := strings.CutPrefix(path, "identity/oidc/token/"); found {
+		mv.mu.Lock()
+		known := mv.oidcRoles[role]
+		mv.mu.Unlock()
+		if !known {
+			writeJson(w, http.StatusBadRequest, fmt.Sprintf(`{"errors":["role %q not found"]}`, role))
+			return
+		}
+		// the token says who it was issued to, in place of a signed jwt
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"client_id":"client-%s","token":"oidc.%s.%s","ttl":3600}}`, role, role, token))
+		return
+	}
+
+	switch path {
+	case "auth/token/lookup-self":
+		mv.lookups.Add(1)
+		mv.mu.Lock()
+		entityId := ""
+		if mv.groupNames != nil {
+			entityId = "entity-1"
+		}
+		body := fmt.Sprintf(`{"data":{"id":%q,"accessor":"acc-%s","policies":["default"],"ttl":%d,"explicit_max_ttl":%d,"renewable":%t,"entity_id":%q}}`,
+			token, token, mv.ttl, mv.explicitMaxTtl, !mv.nonRenewable, entityId)
+		if mv.rootTokens {
+			body = fmt.Sprintf(`{"data":{"id":%q,"accessor":"acc-%s","policies":["root"],"ttl":0,"explicit_max_ttl":0,"renewable":false}}`,
+				token, token)
+		}
+		mv.mu.Unlock()
+		writeJson(w, http.StatusOK, body)
+
+	case "auth/token/accessors":
+		mv.mu.Lock()
+		noSudo := mv.noSudo
+		var accessors []string
+		for issued, valid := range mv.tokens {
+			if valid {
+				accessors = append(accessors, "acc-"+issued)
+			}
+		}
+		mv.mu.Unlock()
+		if noSudo {
+			writeJson(w, http.StatusForbidden, `{"errors":["1 error occurred:\n\t* permission denied\n\n"]}`)
+			return
+		}
+		slices.Sort(accessors)
+		keys, _ := json.Marshal(accessors)
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"keys":%s}}`, keys))
+
+	case "auth/token/create":
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		mv.mu.Lock()
+		mv.createBodies = append(mv.createBodies, body)
+		child := fmt.Sprintf("child-%d", len(mv.createBodies))
+		mv.tokens[child] = true
+		ttl := mv.ttl
+		mv.mu.Unlock()
+		policies, _ := json.Marshal(body["policies"])
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"auth":{"client_token":%q,"accessor":"acc-%s","policies":%s,"lease_duration":%d,"renewable":true}}`,
+			child, child, policies, ttl))
+
+	case "auth/token/renew-self":
+		mv.renewals.Add(1)
+		mv.mu.Lock()
+		gate := mv.renewGate
+		mv.mu.Unlock()
+		if gate != nil {
+			select {
+			case <-gate:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		mv.mu.Lock()
+		body := fmt.Sprintf(`{"auth":{"client_token":%q,"accessor":"acc-%s","policies":["default"],"lease_duration":%d,"renewable":true}}`,
+			token, token, mv.ttl)
+		mv.mu.Unlock()
+		mv.writeTokenJson(w, body)
+
+	case "auth/token/revoke-self":
+		mv.mu.Lock()
+		failRevokes := mv.failRevokes
+		gate := mv.revokeGate
+		mv.mu.Unlock()
+		if gate != nil {
+			// a hung server, deaf to the client giving up
+			<-gate
+		}
+		if failRevokes {
+			writeJson(w, http.StatusBadRequest, `{"errors":["revocation refused"]}`)
+			return
+		}
+		mv.revocations.Add(1)
+		mv.mu.Lock()
+		mv.tokens[token] = false
+		mv.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+
+	case "sys/wrapping/lookup":
+		var body struct {
+			Token string `json:"token"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mv.mu.Lock()
+		creationPath, found := mv.wrappings[body.Token]
+		mv.mu.Unlock()
+		if !found {
+			writeJson(w, http.StatusBadRequest, `{"errors":["wrapping token is not valid or does not exist"]}`)
+			return
+		}
+		writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"creation_path":%q,"creation_time":"2026-10-15T08:00:00Z","creation_ttl":300}}`, creationPath))
+
+	default:
+		if r.Method == http.MethodPut || r.Method == http.MethodPost {
+			mv.write(w, r, path)
+			return
+		}
+		if mount, secretPath, found := strings.Cut(path, "/metadata/"); found {
+			mv.metadata(w, mount+"/data/"+secretPath)
+			return
+		}
+
+		mv.kvReads.Add(1)
+		mv.mu.Lock()
+		mv.readIndexes = append(mv.readIndexes, strings.Join(r.Header.Values("X-Vault-Index"), ","))
+		data, found := mv.secrets[path]
+		current := mv.versions[path]
+		mount, _, _ := strings.Cut(path, "/")
+		v1 := mv.kvV1Mounts[mount]
+		gate := mv.readGate
+		var failStatus int
+		if len(mv.readErrors) > 0 {
+			failStatus = mv.readErrors[0]
+			mv.readErrors = mv.readErrors[1:]
+		}
+		mv.mu.Unlock()
+		if failStatus != 0 {
+			writeJson(w, failStatus, `{"errors":["read failed"]}`)
+			return
+		}
+		if gate != nil {
+			select {
+			case <-gate:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if !found {
+			writeJson(w, http.StatusNotFound, `{"errors":[]}`)
+			return
+		}
+		if v1 {
+			writeJson(w, http.StatusOK, `{"data":`+data+`}`)
+			return
+		}
+		version := r.URL.Query().Get("version")
+		if version == "" {
+			version = fmt.Sprint(current)
+		}
+		writeJson(w, http.StatusOK, `{"data":{"data":`+data+`,"metadata":{"version":`+version+`}}}`)
+	}
+}
+
+// metadata describes the KV v2 secret at dataPath
+func (mv *mockVault) metadata(w http.ResponseWriter, dataPath string) {
+	mv.mu.Lock()
+	current, found := mv.versions[dataPath]
+	mv.mu.Unlock()
+	if !found {
+		writeJson(w, http.StatusNotFound, `{"errors":[]}`)
+		return
+	}
+	writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"current_version":%d,"oldest_version":1,"max_versions":0,"cas_required":false,"delete_version_after":"0s","created_time":"2026-10-15T08:00:00Z","updated_time":"2026-10-15T08:00:00Z","custom_metadata":null,"versions":{}}}`, current))
+}
+
+// write stores a KV v2 secret and reports the replication state of the
+// write in X-Vault-Index, as a performance primary does
+func (mv *mockVault) write(w http.ResponseWriter, r *http.Request, path string) {
+	var body struct {
+		Data    json.RawMessage `json:"data"`
+		Options struct {
+			Cas *int `json:"cas"`
+		} `json:"options"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Data == nil {
+		writeJson(w, http.StatusBadRequest, `{"errors":["no data provided"]}`)
+		return
+	}
+
+	mv.mu.Lock()
+	if body.Options.Cas != nil && *body.Options.Cas != mv.versions[path] {
+		mv.mu.Unlock()
+		writeJson(w, http.StatusBadRequest, `{"errors":["check-and-set parameter did not match the current version"]}`)
+		return
+	}
+	n := mv.writes.Add(1)
+	mv.secrets[path] = string(body.Data)
+	version := mv.bumpVersion(path)
+	mv.mu.Unlock()
+
+	w.Header().Set("X-Vault-Index", fmt.Sprintf("index-%d", n))
+	writeJson(w, http.StatusOK, fmt.Sprintf(`{"data":{"version":%d,"created_time":%q}}`, version, time.Now().UTC().Format(time.RFC3339Nano)))
+}
+
+// lastReadIndex provides the X-Vault-Index of the most recent KV read
+func (mv *mockVault) lastReadIndex() string {
+	mv.mu.Lock()
+	defer mv.mu.Unlock()
+	if len(mv.readIndexes) == 0 {
+		return ""
+	}
+	return mv.readIndexes[len(mv.readIndexes)-1]
+}
+
+// login issues a new token, after waiting for loginGate if it is set
+func (mv *mockVault) login(w http.ResponseWriter, r *http.Request) {
+	mv.mu.Lock()
+	gate := mv.loginGate
+	reject := mv.rejectLogins
+	var failStatus int
+	if len(mv.loginErrors) > 0 {
+		failStatus = mv.loginErrors[0]
+		mv.loginErrors = mv.loginErrors[1:]
+	}
+	retryAfter := mv.retryAfter
+	mv.mu.Unlock()
+	if reject {
+		writeJson(w, http.StatusBadRequest, `{"errors":["invalid role or secret ID"]}`)
+		return
+	}
+	if failStatus != 0 {
+		mv.failedLogins.Add(1)
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		writeJson(w, failStatus, `{"errors":["login failed"]}`)
+		return
+	}
+	if gate != nil {
+		select {
+		case <-gate:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	n := mv.logins.Add(1)
+	token := fmt.Sprintf("tok-%d", n)
+
+	mv.mu.Lock()
+	mv.tokens[token] = true
+	body := fmt.Sprintf(`{"auth":{"client_token":%q,"accessor":"acc-%s","policies":["default"],"token_policies":["default"],"lease_duration":%d,"renewable":%t}}`,
+		token, token, mv.ttl, !mv.nonRenewable)
+	mv.mu.Unlock()
+	mv.writeTokenJson(w, body)
+}
+
+// writeTokenJson sends a successful login or renewal, splitting the body
+// if slowBodies is set
+func (mv *mockVault) writeTokenJson(w http.ResponseWriter, body string) {
+	mv.mu.Lock()
+	slow := mv.slowBodies
+	mv.mu.Unlock()
+	if !slow {
+		writeJson(w, http.StatusOK, body)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	half := len(body) / 2
+	w.Write([]byte(body[:half]))
+	w.(http.Flusher).Flush()
+	time.Sleep(20 * time.Millisecond)
+	w.Write([]byte(body[half:]))
+}
+
+func writeJson(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+// newAppRoleClient connects to the mock server with AppRole auth
+func newAppRoleClient(t testing.TB, l lane.Lane, mv *mockVault, opts ...VaultOption) *VaultClientConnection {
+	vcc, err := NewVaultClientAppRole(l, mv.srv.URL, "", "", "role-id", "secret-id", opts...)
+	if err != nil {
+		t.Fatalf("can't make approle client: %v", err)
+	}
+	return vcc
+}
@@ -0,0 +1,232 @@
+package vaulttoken
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// VaultOption customizes a VaultClientConnection at construction.
+	VaultOption func(opts *vaultOptions)
+
+	// ConsistencyMode selects how reads relate to prior writes on clusters
+	// with performance standbys.
+	ConsistencyMode int
+
+	// RetryNotifyFunc is informed of each failed attempt of a retried
+	// operation, along with the delay chosen before the next attempt.
+	RetryNotifyFunc func(attempt int, err error, delay time.Duration)
+
+	vaultOptions struct {
+		forceHttp1       bool
+		validate         bool
+		consistency      ConsistencyMode
+		transport        VaultTransport
+		beforeLogin      func(l lane.Lane) error
+		maxRequests      int
+		minTls           uint16
+		restartRetries   int
+		codec            JSONCodec
+		gcpOpts          []gcpAuthOption
+		approlePath      string
+		requestedTtl     time.Duration
+		k8sPath          string
+		k8sTokenFile     string
+		jwtPath          string
+		envDefaults      bool
+		namespace        string
+		pathPrefix       string
+		maxResponseBytes int64
+		retryable        RetryableFunc
+		closeStatic      bool
+		caCert           string
+		caPath           string
+		caCertPem        []byte
+		insecure         bool
+		staticToken      string
+		gcpRole          string
+		timeout          time.Duration
+		httpClient       *http.Client
+		inspector        LoginRequestInspector
+		validators       []TokenValidator
+		clock            clock
+		loginTemplate    string
+		newWatcher       watcherFactory
+	}
+)
+
+const (
+	// ConsistencyEventual makes no read-after-write guarantee (the default).
+	ConsistencyEventual ConsistencyMode = iota
+	// ConsistencyReadYourWrites tracks the X-Vault-Index replication state
+	// of responses and presents it on later requests, so a read that lands
+	// on a standby sees this connection's earlier writes.
+	ConsistencyReadYourWrites
+)
+
+// WithForceHTTP1 restricts the Vault client and the GCP signer to HTTP/1.1,
+// for proxies that mishandle HTTP/2.
+func WithForceHTTP1(force bool) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.forceHttp1 = force
+	}
+}
+
+// WithTokenValidation makes NewVaultClient look up a static token, failing
+// if Vault doesn't accept it, and warning if it is a root token.
+func WithTokenValidation() VaultOption {
+	return func(opts *vaultOptions) {
+		opts.validate = true
+	}
+}
+
+// WithConsistency selects the read-after-write consistency of the connection.
+func WithConsistency(mode ConsistencyMode) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.consistency = mode
+	}
+}
+
+// WithBeforeLogin registers a hook that runs before each Vault login, e.g.,
+// to refresh a credential file the login depends on. An error returned by
+// the hook aborts the login.
+func WithBeforeLogin(hook func(l lane.Lane) error) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.beforeLogin = hook
+	}
+}
+
+// WithMaxConcurrentRequests limits the requests the connection has outstanding
+// with Vault at any one time, to protect a shared cluster. Additional requests
+// wait for a free slot, or until the lane's context ends.
+func WithMaxConcurrentRequests(n int) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.maxRequests = n
+	}
+}
+
+// WithMinTLSVersion sets the lowest TLS version accepted from the Vault
+// server, e.g., tls.VersionTLS13. The default is TLS 1.2.
+func WithMinTLSVersion(version uint16) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.minTls = version
+	}
+}
+
+// WithRequestedTTL asks for login tokens that live for ttl rather than the
+// role's default, for auth methods that honor a requested TTL. Vault caps
+// the TTL at the role's max; a warning is logged when that happens.
+func WithRequestedTTL(ttl time.Duration) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.requestedTtl = ttl
+	}
+}
+
+// WithPathPrefix places every Vault request under prefix, e.g., "/vault",
+// for a Vault server exposed by a gateway at https://host/vault.
+func WithPathPrefix(prefix string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.pathPrefix = prefix
+	}
+}
+
+// WithCACert sets the file holding the public cert of the Vault server's CA
+// (e.g., root-ca). Specify WithCACert or WithCAPath, not both.
+func WithCACert(caCert string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.caCert = caCert
+	}
+}
+
+// WithCAPath sets a directory of CA certs for verifying the Vault server.
+func WithCAPath(caPath string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.caPath = caPath
+	}
+}
+
+// WithCACertPEM provides the Vault server's CA cert (or bundle) as PEM bytes
+// already in memory, e.g., from a Kubernetes secret, rather than as a file.
+// A cert file given by WithCACert takes precedence.
+func WithCACertPEM(pem []byte) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.caCertPem = pem
+	}
+}
+
+// WithInsecureSkipVerify disables verification of the Vault server's
+// certificate, for local development against a self-signed Vault. Never use
+// it in production.
+func WithInsecureSkipVerify() VaultOption {
+	return func(opts *vaultOptions) {
+		opts.insecure = true
+	}
+}
+
+// WithStaticToken authenticates with a Vault-issued token rather than a
+// login, typical for local development and testing.
+func WithStaticToken(token string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.staticToken = token
+	}
+}
+
+// WithGCPRole sets the Vault role bound to the GCP service account, used
+// when there is no static token.
+func WithGCPRole(role string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.gcpRole = role
+	}
+}
+
+// WithNamespace sets the Vault Enterprise namespace of the requests.
+func WithNamespace(namespace string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.namespace = namespace
+	}
+}
+
+// WithTimeout bounds each Vault request, including logins, so a stalled
+// server can't hang them. The vaultapi default is 60 seconds. The GCP
+// signer's requests are bounded by WithSignTimeout.
+func WithTimeout(timeout time.Duration) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.timeout = timeout
+	}
+}
+
+// WithHTTPClient sends Vault requests with hc, e.g., to add a proxy, a
+// custom dialer or connection pool tuning, or to stub Vault in tests. The
+// supplied client takes precedence over the CA and TLS options
+// (WithCACert, WithCAPath, WithCACertPEM, WithInsecureSkipVerify,
+// WithMinTLSVersion, WithForceHTTP1), which are not applied to it; configure
+// its transport instead. WithTimeout still applies.
+func WithHTTPClient(hc *http.Client) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.httpClient = hc
+	}
+}
+
+// newVaultOptions applies the caller's options over the defaults
+func newVaultOptions(opts []VaultOption) *vaultOptions {
+	vo := &vaultOptions{
+		transport:     httpVaultTransport{},
+		minTls:        tls.VersionTLS12,
+		codec:         stdJSONCodec{},
+		retryable:     DefaultRetryable,
+		clock:         systemClock{},
+		loginTemplate: kLoginPathTemplate,
+		newWatcher:    newVaultWatcher,
+	}
+	for _, opt := range opts {
+		opt(vo)
+	}
+
+	if vo.inspector != nil {
+		vo.transport = inspectingTransport{VaultTransport: vo.transport, inspect: vo.inspector}
+	}
+	return vo
+}
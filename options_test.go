@@ -0,0 +1,225 @@
+package vaulttoken
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestWithHTTPClientBare(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+
+	// a bare client has a nil transport, which the wrapping round trippers
+	// must not call
+	hc := &http.Client{}
+	vcc := newStaticClient(t, l, mv, WithHTTPClient(hc), WithMaxConcurrentRequests(2))
+
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+	if hc.Transport != nil {
+		t.Error("the caller's client was modified")
+	}
+}
+
+func TestWithHTTPClientBarePathPrefix(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	mv.addToken("static-token")
+
+	gateway := httptest.NewServer(http.StripPrefix("/gw", http.HandlerFunc(mv.serve)))
+	defer gateway.Close()
+
+	vcc, err := NewVaultClient(l, gateway.URL, "", "", "static-token", "", WithHTTPClient(&http.Client{}), WithPathPrefix("gw"))
+	if err != nil {
+		t.Fatalf("can't make client: %v", err)
+	}
+
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+}
+
+func TestMinTLSVersionRefusesOldServer(t *testing.T) {
+	// a refused handshake would otherwise be retried as a network error
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+	noRetry := WithRetryable(func(err error) bool { return false })
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVaultTLS(t, func(srv *httptest.Server) {
+		srv.TLS = &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11}
+	})
+
+	// the default minimum of TLS 1.2 refuses the handshake
+	vcc := newAppRoleClient(t, l, mv, WithCACertPEM(mv.caPem()), noRetry)
+	_, err := vcc.GetApiInterface(l)
+	if err == nil {
+		t.Fatal("expected the TLS 1.1 handshake to be refused")
+	}
+	if !strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("expected a protocol version error, got %v", err)
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no login, got %d", n)
+	}
+
+	// so does an explicit TLS 1.3 minimum
+	vcc = newAppRoleClient(t, l, mv, WithCACertPEM(mv.caPem()), WithMinTLSVersion(tls.VersionTLS13), noRetry)
+	if _, err = vcc.GetApiInterface(l); err == nil {
+		t.Fatal("expected the TLS 1.1 handshake to be refused")
+	}
+
+	// lowering the minimum shows the option reaches the transport
+	vcc = newAppRoleClient(t, l, mv, WithCACertPEM(mv.caPem()), WithMinTLSVersion(tls.VersionTLS10), noRetry)
+	if _, err = vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login with a TLS 1.0 minimum failed: %v", err)
+	}
+}
+
+func TestMinTLSVersionAcceptsNewServer(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVaultTLS(t, nil)
+
+	vcc := newAppRoleClient(t, l, mv, WithCACertPEM(mv.caPem()), WithMinTLSVersion(tls.VersionTLS13))
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	info, err := vcc.TLSInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MinVersion != tls.VersionTLS13 {
+		t.Errorf("expected a TLS 1.3 minimum, got %x", info.MinVersion)
+	}
+}
+
+// newHttp2MockVault starts an https mock Vault server that offers HTTP/2,
+// reporting the protocol major version of the requests it receives
+func newHttp2MockVault(t *testing.T) (mv *mockVault, protos chan int) {
+	protos = make(chan int, 100)
+	mv = newMockVaultTLS(t, func(srv *httptest.Server) {
+		srv.EnableHTTP2 = true
+		serve := srv.Config.Handler
+		srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case protos <- r.ProtoMajor:
+			default:
+			}
+			serve.ServeHTTP(w, r)
+		})
+	})
+	return
+}
+
+func TestForceHTTP1(t *testing.T) {
+	for _, force := range []bool{false, true} {
+		t.Run(fmt.Sprintf("force=%t", force), func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv, protos := newHttp2MockVault(t)
+
+			vcc := newAppRoleClient(t, l, mv, WithCACertPEM(mv.caPem()), WithForceHTTP1(force))
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+
+			expected := 2
+			if force {
+				expected = 1
+			}
+			if proto := <-protos; proto != expected {
+				t.Errorf("expected HTTP/%d, got HTTP/%d", expected, proto)
+			}
+		})
+	}
+}
+
+func TestConsistencyReadYourWrites(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []VaultOption
+		wantIndex string
+	}{
+		{"eventual", nil, ""},
+		{"read-your-writes", []VaultOption{WithConsistency(ConsistencyReadYourWrites)}, "index-1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			vcc := newStaticClient(t, l, mv, tc.opts...)
+
+			if _, err := vcc.ReadKVv2(l, "secret", "app"); err == nil {
+				t.Fatal("expected the secret not to exist yet")
+			}
+			if idx := mv.lastReadIndex(); idx != "" {
+				t.Errorf("no write yet, but the read presented index %q", idx)
+			}
+
+			if err := vcc.WriteKVv2(l, "secret", "app", map[string]any{"password": "hunter2"}); err != nil {
+				t.Fatalf("write failed: %v", err)
+			}
+			data, err := vcc.ReadKVv2(l, "secret", "app")
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if data["password"] != "hunter2" {
+				t.Errorf("unexpected secret data %v", data)
+			}
+			if idx := mv.lastReadIndex(); idx != tc.wantIndex {
+				t.Errorf("expected the read to present index %q, got %q", tc.wantIndex, idx)
+			}
+		})
+	}
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	// a refused certificate would otherwise be retried as a network error
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+	noRetry := WithRetryable(func(err error) bool { return false })
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVaultTLS(t, nil)
+
+	// the server's self-signed cert is refused by default
+	vcc := newAppRoleClient(t, l, mv, noRetry)
+	if _, err := vcc.GetApiInterface(l); err == nil {
+		t.Fatal("expected the self-signed certificate to be refused")
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no login, got %d", n)
+	}
+	if strings.Contains(l.EventsToString(), "DISABLED") {
+		t.Error("warned of disabled verification while verifying")
+	}
+
+	// and accepted, loudly, when verification is skipped
+	vcc = newAppRoleClient(t, l, mv, WithInsecureSkipVerify(), noRetry)
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected one login, got %d", n)
+	}
+	if !strings.Contains(l.EventsToString(), "tls verification of the vault server is DISABLED") {
+		t.Errorf("expected a warning of disabled verification:\n%s", l.EventsToString())
+	}
+}
@@ -0,0 +1,111 @@
+package vaulttoken
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// WithRestartRecovery makes the connection's helper methods ride out a Vault
+// server restart. When a request fails the way requests do while Vault
+// restarts (a 5xx response, or the server no longer recognizing the token),
+// the connection logs in again and retries, up to maxRetries times.
+func WithRestartRecovery(maxRetries int) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.restartRetries = maxRetries
+	}
+}
+
+// do runs op with a client bearing a fresh token, applying restart recovery
+// if it is enabled
+func (vcc *VaultClientConnection) do(l lane.Lane, op func(vc *vaultapi.Client) error) (err error) {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = 250 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		var vc *vaultapi.Client
+		dropped := false
+		if vc, err = vcc.GetApiInterface(l); err == nil {
+			clientToken := vc.Token()
+			if err = op(vc); err != nil && vcc.auth != nil && vcc.isTokenRejected(l, vc, clientToken, err) {
+				vcc.dropLogin(l, clientToken)
+				dropped = true
+			}
+		}
+
+		if err == nil || attempt >= vcc.opts.restartRetries || !isRestartError(err) {
+			return
+		}
+
+		// only a refused token is replaced; a 5xx is retried with the same one
+		delay := b.NextBackOff()
+		if dropped {
+			l.Warnf("vault client: vault may be restarting, retrying in %s after a fresh login: %v", delay, err)
+		} else {
+			l.Warnf("vault client: vault may be restarting, retrying in %s: %v", delay, err)
+		}
+
+		select {
+		case <-l.Done():
+			err = l.Err()
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isTokenRejected recognizes a response refusing the request's token. A 403
+// is also how Vault denies a request the token's policies don't allow, so
+// unless Vault said the token is invalid, a lookup of the token decides.
+func (vcc *VaultClientConnection) isTokenRejected(l lane.Lane, vc *vaultapi.Client, clientToken string, err error) bool {
+	var respErr *vaultapi.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	if hasTokenError(respErr) {
+		return true
+	}
+	if respErr.StatusCode != http.StatusForbidden {
+		return false
+	}
+
+	client, cloneErr := vc.CloneWithHeaders()
+	if cloneErr != nil {
+		return false
+	}
+	client.SetToken(clientToken)
+	_, lookupErr := vcc.transport.LookupSelf(l, client)
+	return lookupErr != nil
+}
+
+// isRestartError recognizes failures seen while a Vault server restarts
+func isRestartError(err error) bool {
+	var respErr *vaultapi.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+
+	return respErr.StatusCode >= http.StatusInternalServerError || hasTokenError(respErr)
+}
+
+// isInvalidToken recognizes Vault refusing a token that is missing, revoked
+// or otherwise unknown to it
+func isInvalidToken(err error) bool {
+	var respErr *vaultapi.ResponseError
+	return errors.As(err, &respErr) && hasTokenError(respErr)
+}
+
+// hasTokenError looks for Vault's messages about a missing or invalid token
+func hasTokenError(respErr *vaultapi.ResponseError) bool {
+	for _, msg := range respErr.Errors {
+		if strings.Contains(msg, "missing client token") || strings.Contains(msg, "invalid token") {
+			return true
+		}
+	}
+	return false
+}
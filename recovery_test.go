@@ -0,0 +1,137 @@
+package vaulttoken
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestPolicyDenialKeepsLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	// a 403 for a path the policies don't allow says nothing about the token
+	for range 5 {
+		if _, err := vcc.Read(l, "denied/thing"); err == nil {
+			t.Fatal("expected permission denied")
+		}
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestRejectedTokenDropsLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	mv.revoke(vc.Token())
+
+	// the read fails with the revoked token, which is then forgotten
+	if _, err = vcc.ReadKVv2(l, "secret", "app"); err == nil {
+		t.Fatal("expected the revoked token to be refused")
+	}
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read after re-login failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected 2 logins, got %d", n)
+	}
+}
+
+func TestRejectedTokenConfirmedByLookup(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.bareDenials = true
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	mv.revoke(vc.Token())
+
+	// the bare 403 doesn't say why, so the failed lookup of the token does
+	if _, err = vcc.ReadKVv2(l, "secret", "app"); err == nil {
+		t.Fatal("expected the revoked token to be refused")
+	}
+	if vc, err = vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if mv.isRevoked(vc.Token()) {
+		t.Error("the revoked token is still in use")
+	}
+}
+
+func TestRestartRecoveryRelogsIn(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newAppRoleClient(t, l, mv, WithRestartRecovery(2))
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	mv.revoke(vc.Token())
+
+	// with recovery, the refused read is retried after a fresh login
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected 2 logins, got %d", n)
+	}
+	if !strings.Contains(l.EventsToString(), "after a fresh login") {
+		t.Errorf("expected the retry to be logged as after a fresh login:\n%s", l.EventsToString())
+	}
+}
+
+func TestRestartRecoveryServerErrorKeepsLogin(t *testing.T) {
+	// leave the retrying to restart recovery rather than vaultapi
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newAppRoleClient(t, l, mv, WithRestartRecovery(2))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	mv.readErrors = []int{503}
+
+	// a 5xx is retried with the same token
+	data, err := vcc.ReadKVv2(l, "secret", "app")
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if data["password"] != "hunter2" {
+		t.Errorf("unexpected secret data %v", data)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+	events := l.EventsToString()
+	if !strings.Contains(events, "vault may be restarting") || strings.Contains(events, "after a fresh login") {
+		t.Errorf("expected a retry logged without a fresh login:\n%s", events)
+	}
+}
@@ -0,0 +1,68 @@
+package vaulttoken
+
+import (
+	"sync"
+)
+
+// setRenewer records the running background renewer, returning the function
+// that stops it. The stop function may be called more than once, and waits
+// for the renewer goroutine to finish. auto identifies the StartAutoRenew
+// renewer.
+func (vcc *VaultClientConnection) setRenewer(stopCh, finished chan struct{}, auto bool) (stop func()) {
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			close(stopCh)
+		})
+		<-finished
+
+		vcc.renewMu.Lock()
+		if vcc.renewFinished == finished {
+			vcc.stopRenew = nil
+			vcc.renewFinished = nil
+			vcc.renewAuto = false
+		}
+		vcc.renewMu.Unlock()
+	}
+
+	vcc.renewMu.Lock()
+	vcc.stopRenew = stop
+	vcc.renewFinished = finished
+	vcc.renewAuto = auto
+	vcc.renewMu.Unlock()
+	return
+}
+
+// stopRenewer stops the background renewer, if one is running
+func (vcc *VaultClientConnection) stopRenewer() {
+	vcc.renewMu.Lock()
+	stop := vcc.stopRenew
+	vcc.renewMu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+}
+
+// IsAutoRenewRunning indicates if the background renewal started by
+// StartAutoRenew is running. It becomes false once stopped, when the lane's
+// context ends, or when another renewer replaces it.
+func (vcc *VaultClientConnection) IsAutoRenewRunning() bool {
+	vcc.renewMu.Lock()
+	defer vcc.renewMu.Unlock()
+	return vcc.autoRenewRunning()
+}
+
+// autoRenewRunning is the worker of IsAutoRenewRunning; renewMu must be held
+func (vcc *VaultClientConnection) autoRenewRunning() bool {
+	if !vcc.renewAuto || vcc.renewFinished == nil {
+		return false
+	}
+
+	select {
+	case <-vcc.renewFinished:
+		return false
+	default:
+		return true
+	}
+}
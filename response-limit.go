@@ -0,0 +1,38 @@
+package vaulttoken
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrResponseTooLarge is reported when a response body exceeds the limit set
+// by WithMaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response body exceeds the size limit")
+
+// WithMaxResponseBytes limits the size of the response bodies this package
+// reads itself: the GCP signer's responses and the helper reads such as
+// ReadKVv2. A larger body fails with ErrResponseTooLarge. Zero (the
+// default) means no limit.
+func WithMaxResponseBytes(n int64) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.maxResponseBytes = n
+	}
+}
+
+// readAllLimited reads r to the end, failing if it holds more than
+// maxBytes; a maxBytes of zero or less reads without limit
+func readAllLimited(r io.Reader, maxBytes int64) (body []byte, err error) {
+	if maxBytes <= 0 {
+		return io.ReadAll(r)
+	}
+
+	if body, err = io.ReadAll(io.LimitReader(r, maxBytes+1)); err != nil {
+		return
+	}
+	if int64(len(body)) > maxBytes {
+		body = nil
+		err = fmt.Errorf("%w of %d bytes", ErrResponseTooLarge, maxBytes)
+	}
+	return
+}
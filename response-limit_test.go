@@ -0,0 +1,50 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestMaxResponseBytes(t *testing.T) {
+	// the secret's response envelope is a little over 4KB
+	big := `{"blob":"` + strings.Repeat("x", 4096) + `"}`
+
+	cases := []struct {
+		name     string
+		opts     []VaultOption
+		tooLarge bool
+	}{
+		{"no limit", nil, false},
+		{"under the limit", []VaultOption{WithMaxResponseBytes(8192)}, false},
+		{"over the limit", []VaultOption{WithMaxResponseBytes(1024)}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			mv.putSecret("secret", "big", big)
+			vcc := newStaticClient(t, l, mv, c.opts...)
+
+			data, err := vcc.ReadKVv2(l, "secret", "big")
+			if c.tooLarge {
+				if !errors.Is(err, ErrResponseTooLarge) {
+					t.Errorf("expected ErrResponseTooLarge, got %v", err)
+				}
+				if data != nil {
+					t.Errorf("expected no data, got %d keys", len(data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if blob, _ := data["blob"].(string); len(blob) != 4096 {
+				t.Errorf("unexpected blob of %d bytes", len(blob))
+			}
+		})
+	}
+}
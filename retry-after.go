@@ -0,0 +1,97 @@
+package vaulttoken
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+)
+
+// kRetryAfterMax caps the wait a Retry-After header can impose, matching
+// the longest interval of the default exponential backoff
+const kRetryAfterMax = time.Minute
+
+type (
+	// retryAfterError is implemented by errors of responses that carried a
+	// Retry-After header
+	retryAfterError interface {
+		RetryAfter() time.Duration
+	}
+
+	// retryAfterResponseError keeps the Retry-After of a failed Vault
+	// response alongside the vaultapi error
+	retryAfterResponseError struct {
+		err        error
+		retryAfter time.Duration
+	}
+
+	// retryAfterBackOff waits as long as the last failure's Retry-After asks,
+	// falling back to the wrapped backoff when there is none
+	retryAfterBackOff struct {
+		backoff.BackOff
+		lastErr error
+	}
+)
+
+// parseRetryAfter reads a Retry-After header, which is either a number of
+// seconds or an HTTP date; ok is false for a missing or malformed header
+func parseRetryAfter(value string, now time.Time) (delay time.Duration, ok bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(value); err == nil {
+		if delay = at.Sub(now); delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return
+}
+
+func (e *retryAfterResponseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterResponseError) Unwrap() error {
+	return e.err
+}
+
+// RetryAfter provides the wait requested by the response's Retry-After
+// header
+func (e *retryAfterResponseError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
+// newRetryAfterBackOff wraps b to honor Retry-After; each attempt's error
+// must be passed through record
+func newRetryAfterBackOff(b backoff.BackOff) *retryAfterBackOff {
+	return &retryAfterBackOff{BackOff: b}
+}
+
+// record notes the error of the latest attempt and passes it through
+func (b *retryAfterBackOff) record(err error) error {
+	b.lastErr = err
+	return err
+}
+
+func (b *retryAfterBackOff) NextBackOff() time.Duration {
+	var raErr retryAfterError
+	if errors.As(b.lastErr, &raErr) {
+		if delay := raErr.RetryAfter(); delay > 0 {
+			return min(delay, kRetryAfterMax)
+		}
+	}
+	return b.BackOff.NextBackOff()
+}
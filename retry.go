@@ -0,0 +1,78 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cenkalti/backoff/v3"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// RetryableFunc decides if a failed JWT signing, login or token refresh
+	// is worth retrying.
+	RetryableFunc func(err error) bool
+
+	httpStatusError interface {
+		HTTPStatusCode() int
+	}
+)
+
+// WithRetryable replaces the decision of which errors are retried by the
+// JWT signing, re-login and token refresh retry loops. The default is
+// DefaultRetryable, which is restored by a nil fn.
+func WithRetryable(fn RetryableFunc) VaultOption {
+	return func(opts *vaultOptions) {
+		if fn == nil {
+			fn = DefaultRetryable
+		}
+		opts.retryable = fn
+	}
+}
+
+// DefaultRetryable retries network errors and 5xx (or 429) responses, but
+// not other 4xx responses, which won't succeed by repeating them, nor
+// cancellation, an expired or revoked token, or a token rejected by a
+// TokenValidator.
+func DefaultRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, ErrTokenRejected) || errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrTokenRevoked) {
+		return false
+	}
+
+	status := 0
+	var respErr *vaultapi.ResponseError
+	var statusErr httpStatusError
+	if errors.As(err, &respErr) {
+		status = respErr.StatusCode
+	} else if errors.As(err, &statusErr) {
+		status = statusErr.HTTPStatusCode()
+	}
+
+	if status == 0 {
+		// not an http response, e.g., a network error
+		return true
+	}
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests
+}
+
+// retryOp runs op until it succeeds, fails with an error the connection's
+// retry predicate rejects, or maxRetries retries are used up
+func (vcc *VaultClientConnection) retryOp(l lane.Lane, maxRetries int, op func() error) error {
+	rb := newRetryAfterBackOff(backoff.NewExponentialBackOff())
+	return backoff.Retry(func() error {
+		return permanentUnless(vcc.opts.retryable, rb.record(op()))
+	}, backoff.WithContext(backoff.WithMaxRetries(rb, uint64(maxRetries)), l))
+}
+
+// permanentUnless marks err to stop retries unless retryable accepts it
+func permanentUnless(retryable RetryableFunc, err error) error {
+	if err != nil && retryable != nil && !retryable(err) {
+		return backoff.Permanent(err)
+	}
+	return err
+}
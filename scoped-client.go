@@ -0,0 +1,35 @@
+package vaulttoken
+
+import (
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// ScopedClient creates a child of the current token limited to policies and
+// ttl, and returns a separate client bearing the child token, for carrying out
+// a sub-operation with least privilege. The connection's own client and token
+// are left untouched. The child token is revoked when its parent is.
+func (vcc *VaultClientConnection) ScopedClient(l lane.Lane, policies []string, ttl time.Duration) (scoped *vaultapi.Client, err error) {
+	var secret *vaultapi.Secret
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		secret, err = vc.Auth().Token().CreateWithContext(l, &vaultapi.TokenCreateRequest{
+			Policies: policies,
+			TTL:      ttl.String(),
+		})
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: can't create child token: %v", err)
+		return
+	}
+
+	if scoped, err = vcc.vc.CloneWithHeaders(); err != nil {
+		l.Errorf("vault client: can't clone vault api client for child token: %v", err)
+		return
+	}
+
+	scoped.SetToken(secret.Auth.ClientToken)
+	return
+}
@@ -0,0 +1,61 @@
+package vaulttoken
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestScopedClient(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newAppRoleClient(t, l, mv)
+
+	scoped, err := vcc.ScopedClient(l, []string{"read-app"}, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("can't make scoped client: %v", err)
+	}
+
+	// the child token was asked for with the requested scope
+	mv.mu.Lock()
+	create := mv.createBodies[len(mv.createBodies)-1]
+	mv.mu.Unlock()
+	policies, _ := create["policies"].([]any)
+	if !slices.Equal(policies, []any{"read-app"}) {
+		t.Errorf("unexpected child policies %v", create["policies"])
+	}
+	if create["ttl"] != "5m0s" {
+		t.Errorf("unexpected child ttl %v", create["ttl"])
+	}
+
+	// the scoped client bears the child token at the server
+	if scoped.Token() != "child-1" {
+		t.Errorf("expected the child token, got %s", scoped.Token())
+	}
+	secret, err := scoped.Auth().Token().LookupSelfWithContext(l)
+	if err != nil {
+		t.Fatalf("lookup by the scoped client failed: %v", err)
+	}
+	if id := secret.Data["id"]; id != "child-1" {
+		t.Errorf("the scoped client presented %v", id)
+	}
+
+	// the connection keeps its own token
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("can't get the api: %v", err)
+	}
+	if vc.Token() != "tok-1" {
+		t.Errorf("the parent's token changed to %s", vc.Token())
+	}
+	if _, err = vcc.ReadKVv2(l, "secret", "app"); err != nil {
+		t.Errorf("the parent can't read after scoping: %v", err)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
@@ -0,0 +1,105 @@
+package vaulttoken
+
+import (
+	"context"
+
+	"github.com/jimsnab/go-lane"
+)
+
+// Shutdown prepares the connection for application exit: it stops
+// background token renewal and makes a best-effort revoke of the login
+// token, bounded by ctx. It returns the revoke error, or the context error
+// if the deadline passes first, and never blocks beyond the deadline. A
+// static token is not revoked.
+//
+// If ctx is a lane, it is used for logging.
+func (vcc *VaultClientConnection) Shutdown(ctx context.Context) (err error) {
+	l := laneFromContext(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		vcc.stopRenewer()
+		done <- vcc.revokeLogin(l)
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+		l.Warnf("vault client: shutdown deadline reached before token revocation completed")
+	}
+	return
+}
+
+// WithRevokeStaticTokenOnClose makes Close revoke a static token too. By
+// default, a static token is left alone since its lifetime is managed by
+// whoever provided it.
+func WithRevokeStaticTokenOnClose() VaultOption {
+	return func(opts *vaultOptions) {
+		opts.closeStatic = true
+	}
+}
+
+// Close tears down the connection: it stops background token renewal,
+// revokes the login token (and a static token if WithRevokeStaticTokenOnClose
+// is set) so it doesn't linger until expiration, and closes idle HTTP
+// connections.
+//
+// Close is idempotent, and safe to call on a connection that never
+// authenticated.
+func (vcc *VaultClientConnection) Close(l lane.Lane) (err error) {
+	vcc.stopRenewer()
+
+	if vcc.auth != nil {
+		err = vcc.revokeLogin(l)
+	} else if vcc.opts.closeStatic && vcc.vc.Token() != "" {
+		if err = vcc.transport.RevokeSelf(l, vcc.vc); err == nil {
+			vcc.stats.revocations.Add(1)
+			vcc.vc.ClearToken()
+		} else {
+			vcc.stats.recordError(err)
+		}
+	}
+	if err != nil {
+		l.Warnf("vault client: unable to revoke token on close: %v", err)
+	}
+
+	if vcc.httpTransport != nil {
+		vcc.httpTransport.CloseIdleConnections()
+	}
+	return
+}
+
+// release discards the login token of a connection that authenticated with
+// a cloud provider. Failure is not fatal since the token expires on its own.
+func (vcc *VaultClientConnection) release(l lane.Lane) {
+	if err := vcc.revokeLogin(l); err != nil {
+		l.Warnf("vault client: unable to revoke token: %v", err)
+	}
+}
+
+// revokeLogin revokes the token obtained by login, if there is one
+func (vcc *VaultClientConnection) revokeLogin(l lane.Lane) (err error) {
+	if vcc.auth == nil {
+		return
+	}
+
+	vc := vcc.vc
+	if vc.Token() == "" {
+		return
+	}
+
+	if err = vcc.transport.RevokeSelf(l, vc); err != nil {
+		vcc.stats.recordError(err)
+		return
+	}
+	vcc.stats.revocations.Add(1)
+
+	// forget the login too, so the next GetApiInterface logs in again
+	vcc.loginMu.Lock()
+	vcc.provider = nil
+	vcc.lastLogin = nil
+	vc.ClearToken()
+	vcc.loginMu.Unlock()
+	return
+}
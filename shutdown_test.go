@@ -0,0 +1,66 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestShutdownRevokes(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := vc.Token()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err = vcc.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	if !mv.isRevoked(token) {
+		t.Error("expected the login token to be revoked")
+	}
+}
+
+func TestShutdownReturnsByDeadlineWhenRevokeHangs(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatal(err)
+	}
+
+	gate := make(chan struct{})
+	mv.mu.Lock()
+	mv.revokeGate = gate
+	mv.mu.Unlock()
+	// free the hung handler before the server closes
+	t.Cleanup(func() { close(gate) })
+
+	const deadline = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), deadline)
+	defer cancel()
+
+	start := time.Now()
+	err := vcc.Shutdown(ctx)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline error, got %v", err)
+	}
+	if elapsed > deadline+time.Second {
+		t.Errorf("shutdown took %v, past its %v deadline", elapsed, deadline)
+	}
+	if n := mv.revocations.Load(); n != 0 {
+		t.Errorf("expected no completed revocation, got %d", n)
+	}
+}
@@ -0,0 +1,100 @@
+package vaulttoken
+
+import (
+	"fmt"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// staticToken implements VaultToken for a token provided by the caller,
+	// so it has the same lifecycle as a login token
+	staticToken struct {
+		token     string
+		client    *vaultapi.Client
+		transport VaultTransport
+	}
+)
+
+// newStaticToken returns a VaultToken that wraps the caller's token
+func newStaticToken(token string, client *vaultapi.Client, transport VaultTransport) *staticToken {
+	return &staticToken{
+		token:     token,
+		client:    client,
+		transport: transport,
+	}
+}
+
+// getToken provides a secret holding the static token; it has no lease
+// information because the token wasn't obtained by login
+func (st *staticToken) getToken(l lane.Lane) (token *vaultapi.Secret, err error) {
+	token = &vaultapi.Secret{
+		Auth: &vaultapi.SecretAuth{
+			ClientToken: st.token,
+		},
+	}
+	return
+}
+
+// expiresAt reports no expiration, since the static token's lease isn't
+// tracked; Vault is asked by isExpired instead
+func (st *staticToken) expiresAt() time.Time {
+	return neverExpires
+}
+
+// renewed has nothing to record, since the static token's lease isn't
+// tracked
+func (st *staticToken) renewed(ttl time.Duration) {
+}
+
+// isExpired asks Vault about the token; Vault refuses the lookup of a token
+// that has expired
+func (st *staticToken) isExpired(l lane.Lane) (expired bool, err error) {
+	return st.isRejected(l)
+}
+
+// isRevoked asks Vault about the token; Vault refuses the lookup of a token
+// that has been revoked
+func (st *staticToken) isRevoked(l lane.Lane) (revoked bool, err error) {
+	return st.isRejected(l)
+}
+
+// isRejected looks up the token, indicating if Vault no longer accepts it
+func (st *staticToken) isRejected(l lane.Lane) (rejected bool, err error) {
+	var client *vaultapi.Client
+	if client, err = st.client.CloneWithHeaders(); err != nil {
+		err = fmt.Errorf("%w: %w", ErrClientClone, err)
+		l.Errorf("vault client: can't look up static token: %v", err)
+		return
+	}
+	client.SetToken(st.token)
+
+	if _, err = st.transport.LookupSelf(l, client); err != nil {
+		if isPermissionDenied(err) {
+			rejected = true
+			err = nil
+		}
+	}
+	return
+}
+
+// refresh renews the static token, if Vault permits
+func (st *staticToken) refresh(l lane.Lane, nextTtlInSeconds int) (err error) {
+	if _, err = st.transport.RenewSelf(l, st.client, nextTtlInSeconds); err != nil {
+		if isInvalidToken(err) {
+			err = fmt.Errorf("%w: %w", ErrTokenRevoked, err)
+		}
+		l.Errorf("vault client: static token renewal failed: %v", err)
+	}
+	return
+}
+
+// revoke revokes the static token
+func (st *staticToken) revoke(l lane.Lane) (err error) {
+	if err = st.transport.RevokeSelf(l, st.client); err != nil {
+		l.Errorf("vault client: static token revocation failed: %v", err)
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package vaulttoken
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type (
+	// Stats is a snapshot of a connection's activity counters.
+	Stats struct {
+		Logins       uint64 // successful Vault logins
+		Refreshes    uint64 // successful token renewals
+		Revocations  uint64 // successful token revocations
+		SignAttempts uint64 // GCP JWT signing requests, including retries
+		SignFailures uint64 // GCP JWT signing requests that failed
+		LastError    error  // the most recent login, renewal, revocation or signing error
+	}
+
+	connStats struct {
+		logins       atomic.Uint64
+		refreshes    atomic.Uint64
+		revocations  atomic.Uint64
+		signAttempts atomic.Uint64
+		signFailures atomic.Uint64
+
+		errMu   sync.Mutex
+		lastErr error
+	}
+)
+
+// Stats provides the connection's activity counters. It is safe to call
+// concurrently with any other use of the connection.
+func (vcc *VaultClientConnection) Stats() Stats {
+	return vcc.stats.snapshot()
+}
+
+func (cs *connStats) snapshot() Stats {
+	cs.errMu.Lock()
+	lastErr := cs.lastErr
+	cs.errMu.Unlock()
+
+	return Stats{
+		Logins:       cs.logins.Load(),
+		Refreshes:    cs.refreshes.Load(),
+		Revocations:  cs.revocations.Load(),
+		SignAttempts: cs.signAttempts.Load(),
+		SignFailures: cs.signFailures.Load(),
+		LastError:    lastErr,
+	}
+}
+
+// recordError notes err as the last error; a nil stats is ignored
+func (cs *connStats) recordError(err error) {
+	if cs == nil || err == nil {
+		return
+	}
+	cs.errMu.Lock()
+	cs.lastErr = err
+	cs.errMu.Unlock()
+}
+
+// recordSign counts a signing attempt and its failure; a nil stats is ignored
+func (cs *connStats) recordSign(err error) {
+	if cs == nil {
+		return
+	}
+	cs.signAttempts.Add(1)
+	if err != nil {
+		cs.signFailures.Add(1)
+		cs.recordError(err)
+	}
+}
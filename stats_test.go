@@ -0,0 +1,90 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestStats(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if stats := vcc.Stats(); stats != (Stats{}) {
+		t.Errorf("expected zero stats before use, got %+v", stats)
+	}
+
+	// login, two renewals, a revoke, then the login that replaces it
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	for range 2 {
+		if err := vcc.RefreshToken(l, 3600); err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+	}
+	if err := vcc.RevokeToken(l); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("re-login failed: %v", err)
+	}
+
+	stats := vcc.Stats()
+	if stats.Logins != 2 || stats.Refreshes != 2 || stats.Revocations != 1 {
+		t.Errorf("expected 2 logins, 2 refreshes and 1 revocation, got %+v", stats)
+	}
+	if stats.LastError != nil {
+		t.Errorf("expected no error yet, got %v", stats.LastError)
+	}
+
+	// a failed renewal is the last error and isn't counted as a refresh
+	mv.revoke(vc.Token())
+	if err = vcc.RefreshToken(l, 3600); err == nil {
+		t.Fatal("expected the refresh of a revoked token to fail")
+	}
+	stats = vcc.Stats()
+	if stats.Refreshes != 2 {
+		t.Errorf("expected the failed refresh not to count, got %d", stats.Refreshes)
+	}
+	if !errors.Is(stats.LastError, ErrTokenRevoked) {
+		t.Errorf("expected the refresh failure as the last error, got %v", stats.LastError)
+	}
+}
+
+func TestStatsConcurrentReads(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	// run with -race: reading stats while the connection works is safe
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if _, err := vcc.GetApiInterface(l); err != nil {
+					t.Errorf("can't get the api: %v", err)
+				}
+				vcc.RefreshToken(l, 3600)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				vcc.Stats()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := vcc.Stats(); stats.Logins != 1 || stats.Refreshes != 80 {
+		t.Errorf("expected 1 login and 80 refreshes, got %+v", stats)
+	}
+}
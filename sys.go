@@ -0,0 +1,141 @@
+package vaulttoken
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// LicenseInfo describes the license of a Vault Enterprise server.
+	LicenseInfo struct {
+		LicenseID       string
+		ExpirationTime  time.Time
+		TerminationTime time.Time
+		Features        []string
+	}
+)
+
+// ErrNotEnterprise is reported for an Enterprise-only request made to a
+// Vault server that isn't Enterprise.
+var ErrNotEnterprise = errors.New("vault server is not enterprise")
+
+// LookupWrapping inspects a response-wrapping token without unwrapping it,
+// so the caller can confirm the creation path and TTL before consuming it.
+func (vcc *VaultClientConnection) LookupWrapping(l lane.Lane, token string) (secret *vaultapi.Secret, err error) {
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		secret, err = vc.Logical().WriteWithContext(l, "sys/wrapping/lookup", map[string]any{"token": token})
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: wrapping token lookup failed: %v", err)
+		return
+	}
+	return
+}
+
+// MountTuning reads the default and maximum lease TTLs configured for mount
+// (sys/mounts/<mount>/tune), e.g., to plan token or lease renewal windows.
+func (vcc *VaultClientConnection) MountTuning(l lane.Lane, mount string) (defaultTtl, maxTtl time.Duration, err error) {
+	var tune *vaultapi.MountConfigOutput
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		tune, err = vc.Sys().MountConfigWithContext(l, mount)
+		return
+	})
+	if err != nil {
+		l.Errorf("vault client: can't read tuning of mount %s: %v", mount, err)
+		return
+	}
+
+	defaultTtl = time.Duration(tune.DefaultLeaseTTL) * time.Second
+	maxTtl = time.Duration(tune.MaxLeaseTTL) * time.Second
+	return
+}
+
+// ClusterID provides the ID of the Vault cluster, from sys/health. The ID is
+// read once and cached; an app can compare it to a fresh read through a new
+// connection to detect being redirected to a different cluster, e.g., after
+// a DR promotion.
+func (vcc *VaultClientConnection) ClusterID(l lane.Lane) (clusterId string, err error) {
+	vcc.clusterMu.Lock()
+	defer vcc.clusterMu.Unlock()
+
+	if vcc.clusterId != "" {
+		clusterId = vcc.clusterId
+		return
+	}
+
+	// sys/health doesn't require a token
+	var health *vaultapi.HealthResponse
+	if health, err = vcc.vc.Sys().HealthWithContext(l); err != nil {
+		l.Errorf("vault client: can't read vault health: %v", err)
+		return
+	}
+
+	if health.ClusterID == "" {
+		err = errors.New("vault did not report a cluster id")
+		l.Errorf("vault client: %v", err)
+		return
+	}
+
+	vcc.clusterId = health.ClusterID
+	clusterId = vcc.clusterId
+	return
+}
+
+// LicenseStatus reads the license of a Vault Enterprise server
+// (sys/license/status), so an app can warn before a lapse affects it. For a
+// server that isn't Enterprise, the error wraps ErrNotEnterprise.
+func (vcc *VaultClientConnection) LicenseStatus(l lane.Lane) (info *LicenseInfo, err error) {
+	var secret *vaultapi.Secret
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		secret, err = vc.Logical().ReadWithContext(l, "sys/license/status")
+		return
+	})
+	if err != nil {
+		var respErr *vaultapi.ResponseError
+		if errors.As(err, &respErr) && respErr.StatusCode == http.StatusNotFound {
+			err = fmt.Errorf("%w: %w", ErrNotEnterprise, err)
+		}
+		l.Errorf("vault client: can't read license status: %v", err)
+		return
+	}
+	if secret == nil || secret.Data == nil {
+		err = ErrNotEnterprise
+		l.Errorf("vault client: can't read license status: %v", err)
+		return
+	}
+
+	// the license in effect is the autoloaded one when autoloading is used
+	license, _ := secret.Data["persisted_autoload"].(map[string]any)
+	if autoloaded, ok := secret.Data["autoloaded"].(map[string]any); ok {
+		license = autoloaded
+	}
+	if license == nil {
+		err = errors.New("license status response has no license")
+		l.Errorf("vault client: %v", err)
+		return
+	}
+
+	li := LicenseInfo{}
+	li.LicenseID, _ = license["license_id"].(string)
+	if exp, _ := license["expiration_time"].(string); exp != "" {
+		li.ExpirationTime, _ = time.Parse(time.RFC3339, exp)
+	}
+	if term, _ := license["termination_time"].(string); term != "" {
+		li.TerminationTime, _ = time.Parse(time.RFC3339, term)
+	}
+	features, _ := license["features"].([]any)
+	for _, f := range features {
+		if feature, ok := f.(string); ok {
+			li.Features = append(li.Features, feature)
+		}
+	}
+
+	info = &li
+	return
+}
@@ -0,0 +1,194 @@
+package vaulttoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestLookupWrapping(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.addWrapping("wrap-1", "auth/approle/role/my-role/secret-id")
+	vcc := newStaticClient(t, l, mv)
+
+	secret, err := vcc.LookupWrapping(l, "wrap-1")
+	if err != nil {
+		t.Fatalf("lookup failed: %v", err)
+	}
+	if path := secret.Data["creation_path"]; path != "auth/approle/role/my-role/secret-id" {
+		t.Errorf("unexpected creation path %v", path)
+	}
+	if ttl, _ := secret.Data["creation_ttl"].(json.Number).Int64(); ttl != 300 {
+		t.Errorf("unexpected creation ttl %v", secret.Data["creation_ttl"])
+	}
+
+	// the lookup doesn't consume the wrapping token
+	if _, err = vcc.LookupWrapping(l, "wrap-1"); err != nil {
+		t.Errorf("second lookup failed: %v", err)
+	}
+}
+
+func TestLookupWrappingUnknownToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	if _, err := vcc.LookupWrapping(l, "wrap-unknown"); err == nil {
+		t.Error("expected an unknown wrapping token to fail")
+	}
+}
+
+func TestMountTuning(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.tuneMount("secret", 3600, 86400)
+	vcc := newStaticClient(t, l, mv)
+
+	defaultTtl, maxTtl, err := vcc.MountTuning(l, "secret")
+	if err != nil {
+		t.Fatalf("can't read tuning: %v", err)
+	}
+	if defaultTtl != time.Hour {
+		t.Errorf("expected a 1h default ttl, got %v", defaultTtl)
+	}
+	if maxTtl != 24*time.Hour {
+		t.Errorf("expected a 24h max ttl, got %v", maxTtl)
+	}
+}
+
+func TestMountTuningUnknownMount(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	if _, _, err := vcc.MountTuning(l, "nowhere"); err == nil {
+		t.Error("expected an unknown mount to fail")
+	}
+}
+
+func TestClusterID(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.clusterId = "c0ffee00-1111-2222-3333-444455556666"
+	vcc := newAppRoleClient(t, l, mv)
+
+	for range 3 {
+		clusterId, err := vcc.ClusterID(l)
+		if err != nil {
+			t.Fatalf("can't read cluster id: %v", err)
+		}
+		if clusterId != "c0ffee00-1111-2222-3333-444455556666" {
+			t.Errorf("unexpected cluster id %s", clusterId)
+		}
+	}
+
+	// read once and cached; it needs no login
+	if n := mv.healthChecks.Load(); n != 1 {
+		t.Errorf("expected 1 health check, got %d", n)
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no login, got %d", n)
+	}
+}
+
+func TestClusterIDDetectsNewCluster(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.clusterId = "cluster-a"
+	vcc := newStaticClient(t, l, mv)
+
+	before, err := vcc.ClusterID(l)
+	if err != nil {
+		t.Fatalf("can't read cluster id: %v", err)
+	}
+
+	// after a DR promotion, the cached id differs from a fresh connection's
+	mv.mu.Lock()
+	mv.clusterId = "cluster-b"
+	mv.mu.Unlock()
+	after, err := newStaticClient(t, l, mv).ClusterID(l)
+	if err != nil {
+		t.Fatalf("can't read cluster id: %v", err)
+	}
+	if cached, _ := vcc.ClusterID(l); cached != before {
+		t.Errorf("the cached id changed to %s", cached)
+	}
+	if before == after {
+		t.Errorf("expected a different cluster, both are %s", before)
+	}
+}
+
+func TestClusterIDMissing(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	if _, err := vcc.ClusterID(l); err == nil {
+		t.Error("expected an error when vault reports no cluster id")
+	}
+
+	// a failure isn't cached
+	mv.mu.Lock()
+	mv.clusterId = "cluster-a"
+	mv.mu.Unlock()
+	if clusterId, err := vcc.ClusterID(l); err != nil || clusterId != "cluster-a" {
+		t.Errorf("expected cluster-a after the failure, got %q, %v", clusterId, err)
+	}
+}
+
+func TestLicenseStatus(t *testing.T) {
+	cases := []struct {
+		name    string
+		license string
+	}{
+		{
+			name:    "persisted",
+			license: `{"persisted_autoload":{"license_id":"lic-1","expiration_time":"2031-01-01T00:00:00Z","termination_time":"2031-02-01T00:00:00Z","features":["Namespaces","DR Replication"]}}`,
+		},
+		{
+			// the autoloaded license is the one in effect
+			name:    "autoloaded",
+			license: `{"autoloaded":{"license_id":"lic-1","expiration_time":"2031-01-01T00:00:00Z","termination_time":"2031-02-01T00:00:00Z","features":["Namespaces","DR Replication"]},"persisted_autoload":{"license_id":"lic-old","features":[]}}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			mv.license = c.license
+			vcc := newAppRoleClient(t, l, mv)
+
+			info, err := vcc.LicenseStatus(l)
+			if err != nil {
+				t.Fatalf("can't read license status: %v", err)
+			}
+			if info.LicenseID != "lic-1" {
+				t.Errorf("unexpected license id %s", info.LicenseID)
+			}
+			if !info.ExpirationTime.Equal(time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("unexpected expiration %v", info.ExpirationTime)
+			}
+			if !info.TerminationTime.Equal(time.Date(2031, 2, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("unexpected termination %v", info.TerminationTime)
+			}
+			if len(info.Features) != 2 || info.Features[0] != "Namespaces" {
+				t.Errorf("unexpected features %v", info.Features)
+			}
+		})
+	}
+}
+
+func TestLicenseStatusNotEnterprise(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.LicenseStatus(l); !errors.Is(err, ErrNotEnterprise) {
+		t.Errorf("expected ErrNotEnterprise, got %v", err)
+	}
+}
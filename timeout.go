@@ -0,0 +1,16 @@
+package vaulttoken
+
+import (
+	"context"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+// WithOperationTimeout derives a lane whose context expires after d. Pass the
+// derived lane to a VaultClientConnection method to bound the whole operation,
+// including login, JWT signing and the Vault request itself. Call the returned
+// cancel function when the operation completes.
+func WithOperationTimeout(l lane.Lane, d time.Duration) (lane.Lane, context.CancelFunc) {
+	return l.DeriveWithTimeout(d)
+}
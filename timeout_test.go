@@ -0,0 +1,85 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestOperationTimeoutBoundsRead(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newStaticClient(t, l, mv)
+
+	// a read that never completes
+	gate := make(chan struct{})
+	defer close(gate)
+	mv.mu.Lock()
+	mv.readGate = gate
+	mv.mu.Unlock()
+
+	ol, cancel := WithOperationTimeout(l, 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := vcc.ReadKVv2(ol, "secret", "app")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the read took %v, past its deadline", elapsed)
+	}
+}
+
+func TestOperationTimeoutBoundsLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	// a login that never completes
+	gate := make(chan struct{})
+	defer close(gate)
+	mv.mu.Lock()
+	mv.loginGate = gate
+	mv.mu.Unlock()
+
+	ol, cancel := WithOperationTimeout(l, 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := vcc.GetApiInterface(ol)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the login took %v, past its deadline", elapsed)
+	}
+}
+
+func TestTimeoutBoundsStalledLogin(t *testing.T) {
+	// leave the retrying to the login loop, which won't retry here
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv, WithTimeout(100*time.Millisecond), WithRetryable(func(error) bool { return false }))
+
+	// a server that accepts the login but never answers
+	gate := make(chan struct{})
+	defer close(gate)
+	mv.mu.Lock()
+	mv.loginGate = gate
+	mv.mu.Unlock()
+
+	start := time.Now()
+	if _, err := vcc.GetApiInterface(l); err == nil {
+		t.Fatal("expected the stalled login to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the login took %v, past its timeout", elapsed)
+	}
+}
@@ -0,0 +1,62 @@
+package vaulttoken
+
+import (
+	"errors"
+	"net/http"
+
+	vaultapi "github.com/hashicorp/vault/api"
+)
+
+type (
+	// TLSInfo describes the effective TLS setup of the connection to Vault.
+	TLSInfo struct {
+		VerifyEnabled bool   // false if server certificate verification is skipped
+		CASource      string // one of the CASource* constants
+		CACert        string // ca pem file path, when CASource is CASourceFile
+		CAPath        string // ca pem(s) dir path, when CASource is CASourcePath
+		ServerName    string // server name override, if any
+		MinVersion    uint16 // minimum TLS version, e.g., tls.VersionTLS12
+	}
+)
+
+const (
+	CASourceSystem = "system" // the system cert pool
+	CASourceFile   = "file"   // a ca pem file
+	CASourcePath   = "path"   // a directory of ca pem files
+	CASourcePem    = "pem"    // ca pem bytes held in memory
+)
+
+// TLSInfo reports the TLS configuration built for the connection, for audits.
+func (vcc *VaultClientConnection) TLSInfo() (info *TLSInfo, err error) {
+	if vcc.httpTransport == nil || vcc.httpTransport.TLSClientConfig == nil {
+		err = errors.New("tls configuration of the vault client is not available")
+		return
+	}
+	tc := vcc.httpTransport.TLSClientConfig
+
+	info = &TLSInfo{
+		VerifyEnabled: !tc.InsecureSkipVerify,
+		CASource:      CASourceSystem,
+		CACert:        vcc.tlsCfg.CACert,
+		CAPath:        vcc.tlsCfg.CAPath,
+		ServerName:    tc.ServerName,
+		MinVersion:    tc.MinVersion,
+	}
+
+	switch {
+	case vcc.tlsCfg.CACert != "":
+		info.CASource = CASourceFile
+	case len(vcc.tlsCfg.CACertBytes) > 0:
+		info.CASource = CASourcePem
+	case vcc.tlsCfg.CAPath != "":
+		info.CASource = CASourcePath
+	}
+	return
+}
+
+// vaultHttpTransport finds the http.Transport of the vault client config,
+// before any wrapping round trippers are added
+func vaultHttpTransport(vcfg *vaultapi.Config) *http.Transport {
+	t, _ := vcfg.HttpClient.Transport.(*http.Transport)
+	return t
+}
@@ -0,0 +1,90 @@
+package vaulttoken
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestTLSInfo(t *testing.T) {
+	cases := []struct {
+		name       string
+		opts       func(mv *mockVault, dir string) []VaultOption
+		serverName string
+		want       TLSInfo
+	}{
+		{
+			name: "pem",
+			opts: func(mv *mockVault, dir string) []VaultOption {
+				return []VaultOption{WithCACertPEM(mv.caPem())}
+			},
+			want: TLSInfo{VerifyEnabled: true, CASource: CASourcePem, MinVersion: tls.VersionTLS12},
+		},
+		{
+			name: "file",
+			opts: func(mv *mockVault, dir string) []VaultOption {
+				return []VaultOption{WithCACert(filepath.Join(dir, "ca.pem")), WithMinTLSVersion(tls.VersionTLS13)}
+			},
+			want: TLSInfo{VerifyEnabled: true, CASource: CASourceFile, CACert: "ca.pem", MinVersion: tls.VersionTLS13},
+		},
+		{
+			name: "path",
+			opts: func(mv *mockVault, dir string) []VaultOption {
+				return []VaultOption{WithCAPath(dir)}
+			},
+			want: TLSInfo{VerifyEnabled: true, CASource: CASourcePath, CAPath: ".", MinVersion: tls.VersionTLS12},
+		},
+		{
+			name: "server name",
+			opts: func(mv *mockVault, dir string) []VaultOption {
+				return []VaultOption{WithCACertPEM(mv.caPem())}
+			},
+			// a name in the httptest server's certificate
+			serverName: "example.com",
+			want:       TLSInfo{VerifyEnabled: true, CASource: CASourcePem, ServerName: "example.com", MinVersion: tls.VersionTLS12},
+		},
+		{
+			name: "insecure",
+			opts: func(mv *mockVault, dir string) []VaultOption {
+				return []VaultOption{WithInsecureSkipVerify()}
+			},
+			want: TLSInfo{VerifyEnabled: false, CASource: CASourceSystem, MinVersion: tls.VersionTLS12},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv("VAULT_TLS_SERVER_NAME", c.serverName)
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVaultTLS(t, nil)
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "ca.pem"), mv.caPem(), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			// the reported setup is the one that reaches the server
+			vcc := newAppRoleClient(t, l, mv, c.opts(mv, dir)...)
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+
+			info, err := vcc.TLSInfo()
+			if err != nil {
+				t.Fatalf("no tls info: %v", err)
+			}
+			want := c.want
+			if want.CACert != "" {
+				want.CACert = filepath.Join(dir, want.CACert)
+			}
+			if want.CAPath != "" {
+				want.CAPath = filepath.Join(dir, want.CAPath)
+			}
+			if *info != want {
+				t.Errorf("expected %+v, got %+v", want, *info)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package vaulttoken
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// ErrPermissionDenied is reported when Vault refuses an operation to the
+// connection's token.
+var ErrPermissionDenied = errors.New("permission denied")
+
+// ListTokenAccessors lists the accessors of all tokens in Vault, for token
+// management tooling. The token needs sudo on auth/token/accessors;
+// without it, the error wraps ErrPermissionDenied.
+func (vcc *VaultClientConnection) ListTokenAccessors(l lane.Lane) (accessors []string, err error) {
+	var secret *vaultapi.Secret
+	err = vcc.do(l, func(vc *vaultapi.Client) (err error) {
+		secret, err = vc.Logical().ListWithContext(l, "auth/token/accessors")
+		return
+	})
+	if err != nil {
+		if isPermissionDenied(err) {
+			err = fmt.Errorf("%w: listing token accessors requires sudo on auth/token/accessors: %w", ErrPermissionDenied, err)
+		}
+		l.Errorf("vault client: can't list token accessors: %v", err)
+		return
+	}
+
+	if secret == nil || secret.Data == nil {
+		return
+	}
+
+	keys, _ := secret.Data["keys"].([]any)
+	for _, key := range keys {
+		if accessor, ok := key.(string); ok {
+			accessors = append(accessors, accessor)
+		}
+	}
+	return
+}
+
+// isPermissionDenied indicates if Vault refused the request as forbidden
+func isPermissionDenied(err error) bool {
+	var respErr *vaultapi.ResponseError
+	return errors.As(err, &respErr) && respErr.StatusCode == http.StatusForbidden
+}
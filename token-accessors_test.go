@@ -0,0 +1,43 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestListTokenAccessors(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.addToken("other-token")
+	vcc := newStaticClient(t, l, mv)
+
+	accessors, err := vcc.ListTokenAccessors(l)
+	if err != nil {
+		t.Fatalf("can't list accessors: %v", err)
+	}
+	if expected := []string{"acc-other-token", "acc-static-token"}; !slices.Equal(accessors, expected) {
+		t.Errorf("expected accessors %v, got %v", expected, accessors)
+	}
+}
+
+func TestListTokenAccessorsWithoutSudo(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.noSudo = true
+	vcc := newStaticClient(t, l, mv)
+
+	accessors, err := vcc.ListTokenAccessors(l)
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Errorf("expected ErrPermissionDenied, got %v", err)
+	}
+	if len(accessors) != 0 {
+		t.Errorf("expected no accessors, got %v", accessors)
+	}
+	if errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("a refused list doesn't mean the token is bad: %v", err)
+	}
+}
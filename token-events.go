@@ -0,0 +1,103 @@
+package vaulttoken
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// TokenEventKind classifies a TokenEvent.
+	TokenEventKind int
+
+	// TokenEvent reports a change in the connection's token.
+	TokenEvent struct {
+		Kind TokenEventKind
+		Time time.Time
+		TTL  time.Duration // the token TTL after a login or renewal
+		Err  error         // the cause of a TokenRenewFailed event
+	}
+
+	tokenSubscriber struct {
+		ch chan TokenEvent
+	}
+)
+
+const kTokenWatchBuffer = 16
+
+const (
+	TokenLoggedIn    TokenEventKind = iota // a new token was obtained by login
+	TokenRenewed                           // the token's lease was extended
+	TokenRenewFailed                       // renewal stopped, a login is needed
+)
+
+func (k TokenEventKind) String() string {
+	switch k {
+	case TokenLoggedIn:
+		return "logged-in"
+	case TokenRenewed:
+		return "renewed"
+	case TokenRenewFailed:
+		return "renew-failed"
+	default:
+		return "unknown"
+	}
+}
+
+// SubscribeTokenEvents provides a channel of token events, buffered to hold
+// bufferSize events. Events are dropped for a subscriber whose buffer is full
+// rather than stalling the connection. Call unsubscribe to release the
+// subscription; the channel is closed by it.
+func (vcc *VaultClientConnection) SubscribeTokenEvents(bufferSize int) (events <-chan TokenEvent, unsubscribe func()) {
+	sub := &tokenSubscriber{
+		ch: make(chan TokenEvent, bufferSize),
+	}
+
+	vcc.eventMu.Lock()
+	vcc.subscribers = append(vcc.subscribers, sub)
+	vcc.eventMu.Unlock()
+
+	events = sub.ch
+	unsubscribe = func() {
+		vcc.eventMu.Lock()
+		defer vcc.eventMu.Unlock()
+		for i, s := range vcc.subscribers {
+			if s == sub {
+				vcc.subscribers = append(vcc.subscribers[:i], vcc.subscribers[i+1:]...)
+				close(sub.ch)
+				break
+			}
+		}
+	}
+	return
+}
+
+// WatchTokenEvents provides a channel of token events that is closed when
+// ctx ends, so there's no unsubscribe to call. The channel is buffered like
+// SubscribeTokenEvents, with room for 16 events.
+func (vcc *VaultClientConnection) WatchTokenEvents(ctx context.Context) <-chan TokenEvent {
+	events, unsubscribe := vcc.SubscribeTokenEvents(kTokenWatchBuffer)
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+	}()
+	return events
+}
+
+// publishTokenEvent sends the event to each subscriber with room for it
+func (vcc *VaultClientConnection) publishTokenEvent(kind TokenEventKind, ttl time.Duration, cause error) {
+	ev := TokenEvent{
+		Kind: kind,
+		Time: time.Now(),
+		TTL:  ttl,
+		Err:  cause,
+	}
+
+	vcc.eventMu.Lock()
+	defer vcc.eventMu.Unlock()
+	for _, sub := range vcc.subscribers {
+		select {
+		case sub.ch <- ev:
+		default:
+		}
+	}
+}
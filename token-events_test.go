@@ -0,0 +1,67 @@
+package vaulttoken
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestWatchTokenEvents(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := vcc.WatchTokenEvents(ctx)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Kind != TokenLoggedIn {
+			t.Errorf("expected %v, got %v", TokenLoggedIn, ev.Kind)
+		}
+		if ev.TTL != time.Hour {
+			t.Errorf("expected a TTL of 1h, got %v", ev.TTL)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no login event")
+	}
+}
+
+func TestWatchTokenEventsNoLeak(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := vcc.WatchTokenEvents(ctx)
+	cancel()
+
+	// the channel closes once the context ends
+	select {
+	case _, open := <-events:
+		if open {
+			t.Fatal("expected the channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel wasn't closed after cancel")
+	}
+
+	waitForGoroutines(t, baseline)
+
+	vcc.eventMu.Lock()
+	n := len(vcc.subscribers)
+	vcc.eventMu.Unlock()
+	if n != 0 {
+		t.Errorf("expected no subscribers, got %d", n)
+	}
+}
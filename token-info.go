@@ -0,0 +1,110 @@
+package vaulttoken
+
+import (
+	"slices"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// TokenInfo summarizes the Vault token in use by a connection.
+	TokenInfo struct {
+		Accessor  string
+		Policies  []string
+		TTL       time.Duration
+		Renewable bool
+	}
+)
+
+// neverExpires is the expiration given to tokens without a TTL
+var neverExpires = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// tokenExpiration computes when a token issued at the given time expires. A
+// zero TTL (root or otherwise non-expiring tokens) never expires.
+func tokenExpiration(issued time.Time, ttl time.Duration) time.Time {
+	if ttl == 0 {
+		return neverExpires
+	}
+	return issued.Add(ttl)
+}
+
+// isRootToken inspects a token lookup response for the root policy or the
+// absence of a TTL
+func isRootToken(secret *vaultapi.Secret) bool {
+	policies, _ := secret.TokenPolicies()
+	if slices.Contains(policies, "root") {
+		return true
+	}
+
+	ttl, err := secret.TokenTTL()
+	return err == nil && ttl == 0
+}
+
+// explicitMaxTtl reads the explicit_max_ttl of a token lookup response; zero
+// means there is none
+func explicitMaxTtl(secret *vaultapi.Secret) (ttl time.Duration, err error) {
+	if secret == nil || secret.Data == nil || secret.Data["explicit_max_ttl"] == nil {
+		return
+	}
+	return parseutil.ParseDurationSecond(secret.Data["explicit_max_ttl"])
+}
+
+// newTokenInfo extracts the token details from either a login response or
+// a token lookup response.
+func newTokenInfo(secret *vaultapi.Secret) (info *TokenInfo, err error) {
+	ti := TokenInfo{}
+
+	if ti.Accessor, err = secret.TokenAccessor(); err != nil {
+		return
+	}
+	if ti.Policies, err = secret.TokenPolicies(); err != nil {
+		return
+	}
+	if ti.TTL, err = secret.TokenTTL(); err != nil {
+		return
+	}
+	if ti.Renewable, err = secret.TokenIsRenewable(); err != nil {
+		return
+	}
+
+	info = &ti
+	return
+}
+
+// TokenInfo looks up the current token in Vault, for diagnostics such as
+// confirming the service has the policies it expects. The lookup's data
+// holds the token's policies, accessor, remaining TTL and so on.
+func (vcc *VaultClientConnection) TokenInfo(l lane.Lane) (secret *vaultapi.Secret, err error) {
+	var vc *vaultapi.Client
+	if vc, err = vcc.GetApiInterface(l); err != nil {
+		return
+	}
+
+	if secret, err = vcc.transport.LookupSelf(l, vc); err != nil {
+		l.Errorf("vault client: token lookup failed: %v", err)
+		return
+	}
+	return
+}
+
+// TokenPolicies looks up the policies of the current token.
+func (vcc *VaultClientConnection) TokenPolicies(l lane.Lane) (policies []string, err error) {
+	var secret *vaultapi.Secret
+	if secret, err = vcc.TokenInfo(l); err != nil {
+		return
+	}
+	return secret.TokenPolicies()
+}
+
+// TokenRemainingTTL looks up how long the current token has left. Zero means
+// the token doesn't expire.
+func (vcc *VaultClientConnection) TokenRemainingTTL(l lane.Lane) (ttl time.Duration, err error) {
+	var secret *vaultapi.Secret
+	if secret, err = vcc.TokenInfo(l); err != nil {
+		return
+	}
+	return secret.TokenTTL()
+}
@@ -0,0 +1,61 @@
+package vaulttoken
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestTokenInfo(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.ttl = 1800
+	vcc := newAppRoleClient(t, l, mv)
+
+	secret, err := vcc.TokenInfo(l)
+	if err != nil {
+		t.Fatalf("can't look up the token: %v", err)
+	}
+
+	// the lookup describes the token the client logged in with
+	vc, _ := vcc.GetApiInterface(l)
+	if id, _ := secret.TokenID(); id != vc.Token() {
+		t.Errorf("the lookup describes token %s, not the client's", id)
+	}
+	if accessor, _ := secret.TokenAccessor(); accessor != "acc-"+vc.Token() {
+		t.Errorf("unexpected accessor %s", accessor)
+	}
+
+	policies, err := vcc.TokenPolicies(l)
+	if err != nil || !slices.Equal(policies, []string{"default"}) {
+		t.Errorf("unexpected policies %v, %v", policies, err)
+	}
+
+	ttl, err := vcc.TokenRemainingTTL(l)
+	if err != nil || ttl != 30*time.Minute {
+		t.Errorf("unexpected remaining ttl %v, %v", ttl, err)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestTokenRemainingTTLRootToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.rootTokens = true
+	vcc := newStaticClient(t, l, mv)
+
+	// a token that doesn't expire has no remaining ttl
+	ttl, err := vcc.TokenRemainingTTL(l)
+	if err != nil || ttl != 0 {
+		t.Errorf("expected no remaining ttl, got %v, %v", ttl, err)
+	}
+	policies, err := vcc.TokenPolicies(l)
+	if err != nil || !slices.Equal(policies, []string{"root"}) {
+		t.Errorf("unexpected policies %v, %v", policies, err)
+	}
+}
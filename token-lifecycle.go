@@ -0,0 +1,82 @@
+package vaulttoken
+
+import (
+	"github.com/jimsnab/go-lane"
+)
+
+// IsTokenExpired indicates if the connection's current token has passed its
+// expiration. A login token is judged by its TTL; a static token by asking
+// Vault.
+func (vcc *VaultClientConnection) IsTokenExpired(l lane.Lane) (expired bool, err error) {
+	var provider VaultToken
+	if provider, err = vcc.liveProvider(); err != nil {
+		return
+	}
+	return provider.isExpired(l)
+}
+
+// IsTokenRevoked asks Vault if the connection's current token is still
+// accepted.
+func (vcc *VaultClientConnection) IsTokenRevoked(l lane.Lane) (revoked bool, err error) {
+	var provider VaultToken
+	if provider, err = vcc.liveProvider(); err != nil {
+		return
+	}
+	return provider.isRevoked(l)
+}
+
+// RefreshToken renews the connection's current token, asking for a TTL of
+// ttlSeconds.
+func (vcc *VaultClientConnection) RefreshToken(l lane.Lane, ttlSeconds int) (err error) {
+	var provider VaultToken
+	if provider, err = vcc.liveProvider(); err != nil {
+		return
+	}
+
+	if err = provider.refresh(l, ttlSeconds); err != nil {
+		vcc.stats.recordError(err)
+		return
+	}
+	vcc.stats.refreshes.Add(1)
+	return
+}
+
+// RevokeToken revokes the connection's current token, e.g., ahead of
+// application exit. A login-based connection logs in again on the next
+// GetApiInterface.
+func (vcc *VaultClientConnection) RevokeToken(l lane.Lane) (err error) {
+	var provider VaultToken
+	if provider, err = vcc.liveProvider(); err != nil {
+		return
+	}
+
+	if err = provider.revoke(l); err != nil {
+		vcc.stats.recordError(err)
+		return
+	}
+	vcc.stats.revocations.Add(1)
+
+	// forget the login too, unless a newer one has replaced it meanwhile
+	if vcc.auth != nil {
+		vcc.loginMu.Lock()
+		if vcc.provider == provider {
+			vcc.provider = nil
+			vcc.lastLogin = nil
+			vcc.vc.ClearToken()
+		}
+		vcc.loginMu.Unlock()
+	}
+	return
+}
+
+// liveProvider provides the token provider of the current token
+func (vcc *VaultClientConnection) liveProvider() (provider VaultToken, err error) {
+	vcc.loginMu.Lock()
+	provider = vcc.provider
+	vcc.loginMu.Unlock()
+
+	if provider == nil {
+		err = ErrNoToken
+	}
+	return
+}
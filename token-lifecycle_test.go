@@ -0,0 +1,95 @@
+package vaulttoken
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestRevokeToken(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	first := vc.Token()
+
+	if err = vcc.RevokeToken(l); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if !mv.isRevoked(first) {
+		t.Error("the token wasn't revoked")
+	}
+	if vcc.vc.Token() != "" {
+		t.Error("the revoked token is still set on the client")
+	}
+	if _, err = vcc.IsTokenExpired(l); err == nil {
+		t.Error("expected no token after revocation")
+	}
+}
+
+func TestRevokeTokenFailureKeepsLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.failRevokes = true
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	first := vc.Token()
+
+	if err = vcc.RevokeToken(l); err == nil {
+		t.Fatal("expected the revocation to fail")
+	}
+
+	// the token Vault didn't revoke stays in use
+	if vc, err = vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("get api interface failed: %v", err)
+	}
+	if vc.Token() != first {
+		t.Errorf("expected the token %s to be kept, got %s", first, vc.Token())
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestRevokeTokenConcurrentLogins(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			vcc.RevokeToken(l)
+		}()
+		go func() {
+			defer wg.Done()
+			vcc.GetApiInterface(l)
+		}()
+	}
+	wg.Wait()
+
+	// whatever the interleaving, the client ends up with a live token or none
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("get api interface failed: %v", err)
+	}
+	if mv.isRevoked(vc.Token()) {
+		t.Errorf("the client holds the revoked token %s", vc.Token())
+	}
+}
@@ -0,0 +1,56 @@
+package vaulttoken
+
+import (
+	"errors"
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// TokenValidator checks a freshly logged in token, e.g., that it carries
+	// a required policy or that its TTL is long enough. An error rejects the
+	// login.
+	TokenValidator func(l lane.Lane, info *TokenInfo) error
+)
+
+// ErrTokenRejected is reported when a login token fails a TokenValidator.
+var ErrTokenRejected = errors.New("login token rejected")
+
+// WithTokenValidators registers checks that run after each Vault login. If
+// any fails, the new token is revoked and the login fails with
+// ErrTokenRejected, so the app doesn't proceed with an under-privileged
+// token.
+func WithTokenValidators(validators ...TokenValidator) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.validators = append(opts.validators, validators...)
+	}
+}
+
+// checkLoginToken runs the token validators against a login response,
+// revoking the token if one of them rejects it
+func (vcc *VaultClientConnection) checkLoginToken(l lane.Lane, provider VaultToken, token *vaultapi.Secret) (err error) {
+	if len(vcc.opts.validators) == 0 {
+		return
+	}
+
+	var info *TokenInfo
+	if info, err = newTokenInfo(token); err != nil {
+		l.Errorf("vault client: can't read the login token details: %v", err)
+		return
+	}
+
+	for _, validator := range vcc.opts.validators {
+		if verr := validator(l, info); verr != nil {
+			err = fmt.Errorf("%w: %w", ErrTokenRejected, verr)
+			l.Errorf("vault client: %v", err)
+
+			if rerr := provider.revoke(l); rerr != nil {
+				l.Warnf("vault client: can't revoke the rejected token: %v", rerr)
+			}
+			return
+		}
+	}
+	return
+}
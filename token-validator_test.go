@@ -0,0 +1,75 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+// requirePolicy rejects a token without policy
+func requirePolicy(policy string) TokenValidator {
+	return func(l lane.Lane, info *TokenInfo) error {
+		if !slices.Contains(info.Policies, policy) {
+			return fmt.Errorf("token lacks the %s policy", policy)
+		}
+		return nil
+	}
+}
+
+// requireTTL rejects a token that expires sooner than min
+func requireTTL(min time.Duration) TokenValidator {
+	return func(l lane.Lane, info *TokenInfo) error {
+		if info.TTL < min {
+			return fmt.Errorf("token ttl %v is under %v", info.TTL, min)
+		}
+		return nil
+	}
+}
+
+func TestTokenValidatorsPass(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	vcc := newAppRoleClient(t, l, mv, WithTokenValidators(requirePolicy("default"), requireTTL(time.Hour)))
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if n := mv.revocations.Load(); n != 0 {
+		t.Errorf("expected no revocation, got %d", n)
+	}
+}
+
+func TestTokenValidatorsFail(t *testing.T) {
+	cases := []struct {
+		name       string
+		validators []TokenValidator
+	}{
+		{"policy", []TokenValidator{requirePolicy("admin")}},
+		{"ttl", []TokenValidator{requirePolicy("default"), requireTTL(2 * time.Hour)}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+
+			vcc := newAppRoleClient(t, l, mv, WithTokenValidators(c.validators...))
+			_, err := vcc.GetApiInterface(l)
+			if !errors.Is(err, ErrTokenRejected) {
+				t.Fatalf("expected ErrTokenRejected, got %v", err)
+			}
+
+			// the rejected token is revoked rather than retried
+			if n := mv.logins.Load(); n != 1 {
+				t.Errorf("expected 1 login, got %d", n)
+			}
+			if n := mv.revocations.Load(); n != 1 {
+				t.Errorf("expected the rejected token to be revoked, got %d revocations", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package vaulttoken
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/semaphore"
+)
+
+type (
+	// limitedRoundTripper admits a bounded number of outstanding requests;
+	// a request is outstanding until its response body is closed
+	limitedRoundTripper struct {
+		sem  *semaphore.Weighted
+		next http.RoundTripper
+	}
+
+	// prefixRoundTripper places requests under a base path, for Vault
+	// served behind a path-prefixing gateway
+	prefixRoundTripper struct {
+		prefix string
+		next   http.RoundTripper
+	}
+
+	releasingBody struct {
+		io.ReadCloser
+		once    sync.Once
+		release func()
+	}
+)
+
+// disableHttp2 restricts a transport to HTTP/1.1, undoing the h2 setup that
+// vaultapi (or the Go runtime) may have applied.
+func disableHttp2(t *http.Transport) {
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	if t.TLSClientConfig != nil {
+		t.TLSClientConfig.NextProtos = slices.DeleteFunc(t.TLSClientConfig.NextProtos, func(proto string) bool {
+			return proto == "h2"
+		})
+	}
+}
+
+// newLimitedRoundTripper caps the concurrent requests sent through next
+func newLimitedRoundTripper(next http.RoundTripper, maxRequests int) *limitedRoundTripper {
+	return &limitedRoundTripper{
+		sem:  semaphore.NewWeighted(int64(maxRequests)),
+		next: next,
+	}
+}
+
+// RoundTrip waits for a free slot, giving up if the request context ends
+// first, then sends the request
+func (rt *limitedRoundTripper) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if err = rt.sem.Acquire(req.Context(), 1); err != nil {
+		return
+	}
+
+	if resp, err = rt.next.RoundTrip(req); err != nil {
+		rt.sem.Release(1)
+		return
+	}
+
+	resp.Body = &releasingBody{
+		ReadCloser: resp.Body,
+		release:    func() { rt.sem.Release(1) },
+	}
+	return
+}
+
+func (rb *releasingBody) Close() error {
+	err := rb.ReadCloser.Close()
+	rb.once.Do(rb.release)
+	return err
+}
+
+// newPrefixRoundTripper sends requests through next with prefix ahead of
+// the request path
+func newPrefixRoundTripper(next http.RoundTripper, prefix string) *prefixRoundTripper {
+	return &prefixRoundTripper{
+		prefix: "/" + strings.Trim(prefix, "/"),
+		next:   next,
+	}
+}
+
+// RoundTrip rewrites the request path, leaving a path that already has the
+// prefix (e.g., from the address) as is
+func (rt *prefixRoundTripper) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if req.URL.Path == rt.prefix || strings.HasPrefix(req.URL.Path, rt.prefix+"/") {
+		return rt.next.RoundTrip(req)
+	}
+
+	// a RoundTripper must not modify the caller's request
+	out := req.Clone(req.Context())
+	out.URL.Path = rt.prefix + req.URL.Path
+	if req.URL.RawPath != "" {
+		out.URL.RawPath = rt.prefix + req.URL.RawPath
+	}
+	return rt.next.RoundTrip(out)
+}
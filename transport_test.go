@@ -0,0 +1,187 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+// waitForInFlight waits until the mock server is handling n requests
+func waitForInFlight(t *testing.T, mv *mockVault, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for mv.inFlight.Load() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d requests in flight, have %d", n, mv.inFlight.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMaxConcurrentRequestsCap(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newStaticClient(t, l, mv, WithMaxConcurrentRequests(2))
+
+	gate := make(chan struct{})
+	mv.mu.Lock()
+	mv.readGate = gate
+	mv.mu.Unlock()
+
+	const readers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, readers)
+	for i := 0; i < readers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := vcc.ReadKVv2(l, "secret", "app")
+			errs <- err
+		}()
+	}
+
+	// two reads reach the server; the rest wait for a slot
+	waitForInFlight(t, mv, 2)
+	time.Sleep(50 * time.Millisecond)
+	if n := mv.inFlight.Load(); n != 2 {
+		t.Errorf("expected 2 requests in flight, got %d", n)
+	}
+
+	close(gate)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("read failed: %v", err)
+		}
+	}
+	if n := mv.peakInFlight.Load(); n != 2 {
+		t.Errorf("expected at most 2 concurrent requests, peaked at %d", n)
+	}
+}
+
+func TestMaxConcurrentRequestsCancelWhileWaiting(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	vcc := newStaticClient(t, l, mv, WithMaxConcurrentRequests(1))
+
+	gate := make(chan struct{})
+	mv.mu.Lock()
+	mv.readGate = gate
+	mv.mu.Unlock()
+
+	// occupy the only slot
+	held := make(chan error, 1)
+	go func() {
+		_, err := vcc.ReadKVv2(l, "secret", "app")
+		held <- err
+	}()
+	waitForInFlight(t, mv, 1)
+
+	wl, cancel := l.DeriveWithCancel()
+	waiting := make(chan error, 1)
+	go func() {
+		_, err := vcc.ReadKVv2(wl, "secret", "app")
+		waiting <- err
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	// the waiting request gives up without reaching the server
+	select {
+	case err := <-waiting:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected a canceled error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the waiting request wasn't released by the cancel")
+	}
+	if n := mv.peakInFlight.Load(); n != 1 {
+		t.Errorf("expected 1 request at the server, peaked at %d", n)
+	}
+
+	close(gate)
+	if err := <-held; err != nil {
+		t.Errorf("read holding the slot failed: %v", err)
+	}
+}
+
+// newPrefixGateway serves the mock Vault server under prefix, as a
+// path-prefixing proxy would, recording the paths it receives
+func newPrefixGateway(t *testing.T, mv *mockVault, prefix string) (gateway *httptest.Server, paths *[]string) {
+	var mu sync.Mutex
+	paths = &[]string{}
+	strip := http.StripPrefix(prefix, http.HandlerFunc(mv.serve))
+	gateway = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		*paths = append(*paths, r.URL.Path)
+		mu.Unlock()
+		strip.ServeHTTP(w, r)
+	}))
+	t.Cleanup(gateway.Close)
+	return
+}
+
+func TestPathPrefix(t *testing.T) {
+	for _, prefix := range []string{"/vault", "vault", "/vault/"} {
+		t.Run(prefix, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+			gateway, paths := newPrefixGateway(t, mv, "/vault")
+
+			vcc, err := NewVaultClientAppRole(l, gateway.URL, "", "", "role-id", "secret-id", WithPathPrefix(prefix))
+			if err != nil {
+				t.Fatalf("can't make client: %v", err)
+			}
+			if _, err = vcc.ReadKVv2(l, "secret", "app"); err != nil {
+				t.Fatalf("read failed: %v", err)
+			}
+			if err = vcc.RefreshToken(l, 3600); err != nil {
+				t.Fatalf("refresh failed: %v", err)
+			}
+
+			for _, path := range *paths {
+				if !strings.HasPrefix(path, "/vault/v1/") {
+					t.Errorf("request %s lacks the prefix", path)
+				}
+			}
+			for _, path := range []string{"/vault/v1/auth/approle/login", "/vault/v1/secret/data/app", "/vault/v1/auth/token/renew-self"} {
+				if !slices.Contains(*paths, path) {
+					t.Errorf("no request to %s among %v", path, *paths)
+				}
+			}
+		})
+	}
+}
+
+func TestPathPrefixInAddress(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.putSecret("secret", "app", `{"password":"hunter2"}`)
+	mv.addToken("static-token")
+	gateway, paths := newPrefixGateway(t, mv, "/vault")
+
+	// an address that already has the prefix isn't prefixed twice
+	vcc, err := NewVaultClient(l, gateway.URL+"/vault", "", "", "static-token", "", WithPathPrefix("/vault"))
+	if err != nil {
+		t.Fatalf("can't make client: %v", err)
+	}
+	if _, err = vcc.ReadKVv2(l, "secret", "app"); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if expected := []string{"/vault/v1/secret/data/app"}; !slices.Equal(*paths, expected) {
+		t.Errorf("expected requests %v, got %v", expected, *paths)
+	}
+}
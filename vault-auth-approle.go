@@ -0,0 +1,102 @@
+package vaulttoken
+
+import (
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	approleAuthConfig struct {
+		roleId        string
+		secretId      string
+		authPath      string
+		loginTemplate string
+		transport     VaultTransport
+		requestedTtl  time.Duration
+		retryable     RetryableFunc
+		clock         clock
+	}
+
+	approleAuth struct {
+		secretId string
+		authPath string
+		vo       *vaultOptions
+	}
+)
+
+// newAppRoleAuth makes the AppRole VaultAuth; the role ID is provided to
+// getConfig as the vault role
+func newAppRoleAuth(vo *vaultOptions, secretId string) *approleAuth {
+	return &approleAuth{
+		secretId: secretId,
+		authPath: vo.approlePath,
+		vo:       vo,
+	}
+}
+
+// WithAppRoleAuthPath sets where the AppRole auth method is mounted in
+// Vault. The default is "auth/approle".
+func WithAppRoleAuthPath(path string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.approlePath = path
+	}
+}
+
+// authMethod identifies AppRole auth
+func (auth *approleAuth) authMethod() string {
+	return AuthMethodAppRole
+}
+
+// getConfig provides a config object for newVaultToken
+func (auth *approleAuth) getConfig(l lane.Lane, vaultRole string) (cfg VaultAuthConfig, err error) {
+	arcfg := approleAuthConfig{
+		roleId:        vaultRole,
+		secretId:      auth.secretId,
+		authPath:      "auth/approle",
+		loginTemplate: auth.vo.loginTemplate,
+		transport:     auth.vo.transport,
+		requestedTtl:  auth.vo.requestedTtl,
+		retryable:     auth.vo.retryable,
+		clock:         auth.vo.clock,
+	}
+	if auth.authPath != "" {
+		arcfg.authPath = auth.authPath
+	}
+
+	if err = validateAuthPath(arcfg.authPath); err != nil {
+		l.Errorf("vault-auth-approle: invalid config: %v", err)
+		return
+	}
+	if err = validateLoginTemplate(arcfg.loginTemplate); err != nil {
+		l.Errorf("vault-auth-approle: invalid config: %v", err)
+		return
+	}
+
+	cfg = arcfg
+	return
+}
+
+// snapshot describes the AppRole config for ExportConfig; the role ID and
+// secret ID are credentials, so they are left out
+func (arcfg approleAuthConfig) snapshot(snap *ConfigSnapshot) {
+	snap.AuthPath = arcfg.authPath
+}
+
+func (auth *approleAuth) newVaultToken(l lane.Lane, authCfg VaultAuthConfig, client *vaultapi.Client) (token VaultToken, err error) {
+	arcfg := authCfg.(approleAuthConfig)
+	loginPath := expandLoginPath(arcfg.loginTemplate, arcfg.authPath, arcfg.roleId)
+	lt := newLoginToken(client, arcfg.transport, loginPath, func(l lane.Lane) (jsonData map[string]any, err error) {
+		jsonData = map[string]any{
+			"role_id":   arcfg.roleId,
+			"secret_id": arcfg.secretId,
+		}
+		return
+	})
+	lt.requestedTtl = arcfg.requestedTtl
+	lt.retryable = arcfg.retryable
+	lt.clock = arcfg.clock
+	token = lt
+	return
+}
@@ -0,0 +1,53 @@
+package vaulttoken
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+	"github.com/jimsnab/vault-token-go/vaulttest"
+)
+
+func TestAppRoleLoginLogsNoSecrets(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	l.SetLogLevel(lane.LogLevelTrace)
+	l.WantDescendantEvents(true)
+	mv := newMockVault(t)
+
+	vcc, err := NewVaultClientAppRole(l, mv.srv.URL, "", "", "role-4f2a", "secret-9c1e77d0")
+	if err != nil {
+		t.Fatalf("can't make approle client: %v", err)
+	}
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	token := vc.Token()
+	if err = vcc.Close(l); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	vaulttest.AssertNoSecretsLogged(t, l.EventsToString(), "secret-9c1e77d0", token)
+}
+
+func TestAppRoleFailedLoginLogsNoSecrets(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	l.SetLogLevel(lane.LogLevelTrace)
+	l.WantDescendantEvents(true)
+	mv := newMockVault(t)
+	mv.rejectLogins = true
+
+	vcc, err := NewVaultClientAppRole(l, mv.srv.URL, "", "", "role-4f2a", "secret-9c1e77d0")
+	if err != nil {
+		t.Fatalf("can't make approle client: %v", err)
+	}
+	if _, err = vcc.GetApiInterface(l); err == nil {
+		t.Fatal("expected the login to fail")
+	}
+
+	output := l.EventsToString()
+	if output == "" {
+		t.Fatal("expected the failed login to be logged")
+	}
+	vaulttest.AssertNoSecretsLogged(t, output, "secret-9c1e77d0")
+}
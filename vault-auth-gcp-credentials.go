@@ -0,0 +1,51 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"errors"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2/google"
+)
+
+// GetApiInterfaceWithCredentials makes a one-off GCP login with creds in
+// place of the discovered credentials, e.g., for a multi-tenant server
+// acting on behalf of different service accounts. The Vault role of the
+// connection must accept the service account of creds.
+//
+// The returned client is separate from the connection's client, and its
+// token isn't renewed or revoked by the connection.
+func (vcc *VaultClientConnection) GetApiInterfaceWithCredentials(l lane.Lane, creds *google.Credentials) (vc *vaultapi.Client, err error) {
+	gcpcfg, isGcp := vcc.authCfg.(gcpAuthConfig)
+	if !isGcp {
+		err = errors.New("explicit credentials require a gcp auth connection")
+		l.Errorf("vault client: %v", err)
+		return
+	}
+	if creds == nil {
+		err = errors.New("no credentials provided")
+		l.Errorf("vault client: %v", err)
+		return
+	}
+	gcpcfg.credentials = creds
+
+	var client *vaultapi.Client
+	if client, err = vcc.vc.CloneWithHeaders(); err != nil {
+		l.Errorf("vault client: can't clone vault api client: %v", err)
+		return
+	}
+
+	var token *vaultapi.Secret
+	if token, err = newGcpAuthToken(&gcpcfg, client).getToken(l); err != nil {
+		l.Errorf("vault client: error in vault authentication with explicit credentials: %v", err)
+		vcc.stats.recordError(err)
+		return
+	}
+	vcc.stats.logins.Add(1)
+
+	client.SetToken(token.Auth.ClientToken)
+	vc = client
+	return
+}
@@ -0,0 +1,62 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+func TestGetApiInterfaceWithCredentials(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	var subjects []any
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		subjects = append(subjects, signJwtClaims(t, req)["sub"])
+		return signJwtOk(req)
+	})
+
+	tenant := &google.Credentials{
+		JSON:        []byte(`{"type":"service_account","client_email":"tenant@other.iam.gserviceaccount.com"}`),
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "tenant-access-token"}),
+	}
+	tenantVc, err := vcc.GetApiInterfaceWithCredentials(l, tenant)
+	if err != nil {
+		t.Fatalf("login with explicit credentials failed: %v", err)
+	}
+
+	// the connection's own login still uses its discovered credentials
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	if len(subjects) != 2 || subjects[0] != "tenant@other.iam.gserviceaccount.com" || subjects[1] != "sa@proj.iam.gserviceaccount.com" {
+		t.Errorf("expected jwts for the tenant then the connection, got %v", subjects)
+	}
+	if tenantVc == vc || tenantVc.Token() == vc.Token() {
+		t.Errorf("the tenant client shares the connection's token %s", vc.Token())
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected 2 logins, got %d", n)
+	}
+}
+
+func TestGetApiInterfaceWithCredentialsRequiresGcp(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterfaceWithCredentials(l, testGcpCredentials()); err == nil {
+		t.Error("expected a static token connection to refuse explicit credentials")
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no login, got %d", n)
+	}
+}
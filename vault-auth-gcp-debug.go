@@ -0,0 +1,198 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2"
+)
+
+type (
+	// gcpAuthDebug holds diagnostics shared by every token provider made
+	// from the same GCP auth config
+	gcpAuthDebug struct {
+		mu              sync.Mutex
+		exposeJwt       bool
+		diagnoseToken   bool
+		signedJwt       string
+		accessTokenInfo *AccessTokenInfo
+		tokenDigest     [sha256.Size]byte // identifies the diagnosed token without keeping it
+	}
+
+	// AccessTokenInfo describes the GCP access token that authorized the
+	// signJwt call, without revealing the token itself.
+	AccessTokenInfo struct {
+		Email     string    // identity the token represents, when known
+		Audience  string    // oauth2 client the token was issued to
+		Scopes    []string  // scopes granted to the token
+		TokenType string    // normally "Bearer"
+		Expiry    time.Time // when the token expires
+	}
+)
+
+const kGcpTokenInfoUrl = "https://oauth2.googleapis.com/tokeninfo"
+
+// WithExposeSignedJWT retains the most recently signed GCP login JWT so that
+// SignedJWT can return it for diagnosing claim or audience problems (e.g., by
+// pasting it into jwt.io). The JWT is a short-lived credential; only enable
+// this while debugging.
+func WithExposeSignedJWT(expose bool) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.ensureDebug().exposeJwt = expose
+	})
+}
+
+// WithAccessTokenDiagnostics records details of the GCP access token used
+// for the signJwt call (identity, scopes, expiry; never the token) so that
+// AccessTokenDiagnostics can report them when debugging IAM permissions.
+func WithAccessTokenDiagnostics(enable bool) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.ensureDebug().diagnoseToken = enable
+	})
+}
+
+// ensureDebug provides the config's diagnostics holder, making it if needed
+func (cfg *gcpAuthConfig) ensureDebug() *gcpAuthDebug {
+	if cfg.debug == nil {
+		cfg.debug = &gcpAuthDebug{}
+	}
+	return cfg.debug
+}
+
+// recordSignedJwt keeps the JWT if exposure is enabled
+func (dbg *gcpAuthDebug) recordSignedJwt(signedJwt string) {
+	if dbg == nil || !dbg.exposeJwt {
+		return
+	}
+	dbg.mu.Lock()
+	dbg.signedJwt = signedJwt
+	dbg.mu.Unlock()
+}
+
+// recordAccessToken keeps the details of the access token from tokenSrc if
+// diagnostics are enabled. Failure to get the details is only logged.
+func (dbg *gcpAuthDebug) recordAccessToken(l lane.Lane, hc *http.Client, tokenSrc oauth2.TokenSource, scopes []string, maxBytes int64) {
+	if dbg == nil || !dbg.diagnoseToken {
+		return
+	}
+
+	token, err := tokenSrc.Token()
+	if err != nil {
+		l.Warnf("vault-auth-gcp: access token diagnostics unavailable: %v", err)
+		return
+	}
+
+	// the token source reuses a token until it nears expiry; only a new one
+	// needs diagnosing
+	digest := sha256.Sum256([]byte(token.AccessToken))
+	dbg.mu.Lock()
+	unchanged := dbg.accessTokenInfo != nil && dbg.tokenDigest == digest
+	dbg.mu.Unlock()
+	if unchanged {
+		return
+	}
+
+	info := &AccessTokenInfo{
+		Scopes:    scopes,
+		TokenType: token.Type(),
+		Expiry:    token.Expiry,
+	}
+
+	// ask Google who the token represents
+	var req *http.Request
+	form := url.Values{"access_token": {token.AccessToken}}
+	if req, err = http.NewRequestWithContext(l, http.MethodPost, kGcpTokenInfoUrl, strings.NewReader(form.Encode())); err != nil {
+		l.Warnf("vault-auth-gcp: can't make token info request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var resp *http.Response
+	if resp, err = hc.Do(req); err != nil {
+		l.Warnf("vault-auth-gcp: token info request failed: %v", err)
+	} else {
+		defer resp.Body.Close()
+		body, _ := readAllLimited(resp.Body, maxBytes)
+
+		var data map[string]string
+		if err = json.Unmarshal(body, &data); err != nil || resp.StatusCode != http.StatusOK {
+			l.Warnf("vault-auth-gcp: token info response %d not usable", resp.StatusCode)
+		} else {
+			info.Email = data["email"]
+			info.Audience = data["aud"]
+			if scope := data["scope"]; scope != "" {
+				info.Scopes = strings.Fields(scope)
+			}
+			if secs, convErr := strconv.ParseInt(data["exp"], 10, 64); convErr == nil {
+				info.Expiry = time.Unix(secs, 0)
+			}
+		}
+	}
+
+	dbg.mu.Lock()
+	dbg.accessTokenInfo = info
+	dbg.tokenDigest = digest
+	dbg.mu.Unlock()
+}
+
+// gcpDebug provides the diagnostics holder of a GCP connection
+func (vcc *VaultClientConnection) gcpDebug() *gcpAuthDebug {
+	gcpcfg, isGcp := vcc.authCfg.(gcpAuthConfig)
+	if !isGcp {
+		return nil
+	}
+	return gcpcfg.debug
+}
+
+// SignedJWT returns the most recently signed GCP login JWT. It fails unless
+// the connection was made with WithExposeSignedJWT(true).
+func (vcc *VaultClientConnection) SignedJWT(l lane.Lane) (signedJwt string, err error) {
+	dbg := vcc.gcpDebug()
+	if dbg == nil || !dbg.exposeJwt {
+		err = errors.New("signed jwt exposure is not enabled")
+		return
+	}
+
+	dbg.mu.Lock()
+	signedJwt = dbg.signedJwt
+	dbg.mu.Unlock()
+
+	if signedJwt == "" {
+		err = errors.New("no jwt has been signed")
+		return
+	}
+
+	l.Warnf("vault-auth-gcp: *** exposing the signed login jwt; do not use WithExposeSignedJWT in production ***")
+	return
+}
+
+// AccessTokenDiagnostics describes the GCP access token used for the most
+// recent signJwt call. It fails unless the connection was made with
+// WithAccessTokenDiagnostics(true).
+func (vcc *VaultClientConnection) AccessTokenDiagnostics() (info *AccessTokenInfo, err error) {
+	dbg := vcc.gcpDebug()
+	if dbg == nil || !dbg.diagnoseToken {
+		err = errors.New("access token diagnostics are not enabled")
+		return
+	}
+
+	dbg.mu.Lock()
+	info = dbg.accessTokenInfo
+	dbg.mu.Unlock()
+
+	if info == nil {
+		err = errors.New("no access token has been used")
+		return
+	}
+	return
+}
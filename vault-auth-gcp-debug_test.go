@@ -0,0 +1,157 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+func TestSignedJWTExposure(t *testing.T) {
+	cases := []struct {
+		name   string
+		opts   []VaultOption
+		expose bool
+	}{
+		{"default", nil, false},
+		{"disabled", []VaultOption{WithExposeSignedJWT(false)}, false},
+		{"enabled", []VaultOption{WithExposeSignedJWT(true)}, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			vcc := newGcpClient(t, l, mv, signJwtOk, c.opts...)
+
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+
+			signedJwt, err := vcc.SignedJWT(l)
+			if !c.expose {
+				if err == nil || signedJwt != "" {
+					t.Errorf("the signed jwt was exposed: %q", signedJwt)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("can't get the signed jwt: %v", err)
+			}
+			if signedJwt != "signed.jwt.value" {
+				t.Errorf("unexpected signed jwt %q", signedJwt)
+			}
+			if !strings.Contains(l.EventsToString(), "exposing the signed login jwt") {
+				t.Error("exposing the jwt didn't warn")
+			}
+		})
+	}
+}
+
+func TestSignedJWTBeforeLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newGcpClient(t, l, mv, signJwtOk, WithExposeSignedJWT(true))
+
+	if _, err := vcc.SignedJWT(l); err == nil {
+		t.Error("expected an error before any jwt was signed")
+	}
+}
+
+func TestAccessTokenDiagnostics(t *testing.T) {
+	expiry := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	injected := withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.credentials = &google.Credentials{
+			JSON:        testGcpCredentials().JSON,
+			TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "injected-access-token", TokenType: "Bearer", Expiry: expiry}),
+		}
+	})
+
+	cases := []struct {
+		name    string
+		enabled bool
+	}{
+		{"default", false},
+		{"enabled", true},
+	}
+	for _, c := range cases {
+		enabled := c.enabled
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			fc := newFakeClock()
+
+			var infoRequests []url.Values
+			opts := []VaultOption{injected, withClock(fc)}
+			if enabled {
+				opts = append(opts, WithAccessTokenDiagnostics(true))
+			}
+			vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+				if req.URL.String() == kGcpTokenInfoUrl {
+					req.ParseForm()
+					infoRequests = append(infoRequests, req.PostForm)
+					return jsonResponse(req, http.StatusOK,
+						`{"email":"sa@proj.iam.gserviceaccount.com","aud":"1234.apps.googleusercontent.com","scope":"https://www.googleapis.com/auth/cloud-platform openid","exp":"1792054800"}`), nil
+				}
+				return signJwtOk(req)
+			}, opts...)
+
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+
+			info, err := vcc.AccessTokenDiagnostics()
+			if !enabled {
+				if err == nil || info != nil {
+					t.Errorf("diagnostics were exposed by default: %+v", info)
+				}
+				if len(infoRequests) != 0 {
+					t.Errorf("the access token was sent to google for diagnostics by default")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("no diagnostics: %v", err)
+			}
+			if len(infoRequests) != 1 || infoRequests[0].Get("access_token") != "injected-access-token" {
+				t.Fatalf("expected one token info request for the injected token, got %v", infoRequests)
+			}
+			if info.Email != "sa@proj.iam.gserviceaccount.com" {
+				t.Errorf("unexpected email %s", info.Email)
+			}
+			if info.Audience != "1234.apps.googleusercontent.com" {
+				t.Errorf("unexpected audience %s", info.Audience)
+			}
+			if !slices.Equal(info.Scopes, []string{"https://www.googleapis.com/auth/cloud-platform", "openid"}) {
+				t.Errorf("unexpected scopes %v", info.Scopes)
+			}
+			if info.TokenType != "Bearer" {
+				t.Errorf("unexpected token type %s", info.TokenType)
+			}
+			if !info.Expiry.Equal(time.Unix(1792054800, 0)) {
+				t.Errorf("unexpected expiry %v", info.Expiry)
+			}
+
+			// a re-login with the same access token doesn't ask google again
+			fc.advance(fc.Now().Add(2 * time.Hour))
+			if _, err = vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("re-login failed: %v", err)
+			}
+			if n := mv.logins.Load(); n != 2 {
+				t.Fatalf("expected a re-login, have %d logins", n)
+			}
+			if len(infoRequests) != 1 {
+				t.Errorf("expected the unchanged token not to be diagnosed again, got %d requests", len(infoRequests))
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"net/url"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/jimsnab/go-lane"
+)
+
+// getGceIdentityToken fetches the VM's instance identity token from the
+// metadata server, with the full format that includes the instance details
+// Vault's gce login checks. No signJwt call is involved.
+// see https://developer.hashicorp.com/vault/docs/auth/gcp#gce-login
+func getGceIdentityToken(l lane.Lane, gcpcfg *gcpAuthConfig) (token string, err error) {
+	query := url.Values{
+		"audience": {gcpcfg.audience(gcpcfg.role)},
+		"format":   {"full"},
+	}
+
+	if token, err = metadata.GetWithContext(l, "instance/service-accounts/default/identity?"+query.Encode()); err != nil {
+		l.Errorf("vault-auth-gcp: can't get instance identity token from the metadata server: %v", err)
+		return
+	}
+	return
+}
@@ -1,12 +1,17 @@
+//go:build !nogcp
+
 package vaulttoken
 
 import (
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
@@ -22,16 +27,71 @@ type (
 	gcpAuthJwt struct {
 		cfg *gcpAuthConfig
 	}
+
+	// taggedTokenSource marks the failures of a token source with
+	// ErrGCPTokenSource
+	taggedTokenSource struct {
+		src oauth2.TokenSource
+	}
+
+	// GCPIAMError is the error reported by a Google IAM API, e.g., when the
+	// service account lacks permission to sign a JWT. Status is the Google
+	// status name such as PERMISSION_DENIED or RESOURCE_EXHAUSTED.
+	GCPIAMError struct {
+		Code       int
+		Status     string
+		Message    string
+		retryAfter time.Duration
+	}
 )
 
 const (
-	kJwtTokenTimeoutMins      = 1 // corresponds to Vault policy
+	kJwtTokenTimeoutMins      = 1  // corresponds to Vault policy
+	kJwtMaxTimeoutMins        = 15 // Vault's default max_jwt_exp
+	kSignMaxRetries           = 5
 	kJwtClientIdleTimeoutSecs = 1
 	kGcpAuthUrl               = "https://www.googleapis.com/auth/cloud-platform"
 	kGcpMetadataUrl           = "http://metadata.google.internal/computeMetadata/v1"
 	kGcpIamCredentialsUrl     = "https://iamcredentials.googleapis.com/v1"
+	kGcpIamUrl                = "https://iam.googleapis.com/v1"
 )
 
+func (ts taggedTokenSource) Token() (token *oauth2.Token, err error) {
+	if token, err = ts.src.Token(); err != nil {
+		err = fmt.Errorf("%w: %w", ErrGCPTokenSource, err)
+	}
+	return
+}
+
+// newGCPIAMError extracts the fields of a Google API error object, using the
+// http status code if the object lacks a code
+func newGCPIAMError(obj any, httpStatus int) *GCPIAMError {
+	iamErr := &GCPIAMError{Code: httpStatus}
+	if m, ok := obj.(map[string]any); ok {
+		if code, ok := m["code"].(float64); ok {
+			iamErr.Code = int(code)
+		}
+		iamErr.Status, _ = m["status"].(string)
+		iamErr.Message, _ = m["message"].(string)
+	}
+	return iamErr
+}
+
+func (e *GCPIAMError) Error() string {
+	return fmt.Sprintf("gcp iam error %d %s: %s", e.Code, e.Status, e.Message)
+}
+
+// HTTPStatusCode provides the error code, which follows http status codes
+func (e *GCPIAMError) HTTPStatusCode() int {
+	return e.Code
+}
+
+// RetryAfter provides the wait requested by the response's Retry-After
+// header, or zero if it had none
+func (e *GCPIAMError) RetryAfter() time.Duration {
+	return e.retryAfter
+}
+
 // newGcpAuthJwt creates a structure that wraps a Google Service Account (gsa)
 // signed JWT token. It is a worker class used by gcpAuthToken.
 func newGcpAuthJwt(gcpcfg *gcpAuthConfig) *gcpAuthJwt {
@@ -42,17 +102,46 @@ func newGcpAuthJwt(gcpcfg *gcpAuthConfig) *gcpAuthJwt {
 
 // try creating the signed JWT until success or the number of attempts has been exhausted.
 // On success, the returned JWT is signed by the gsa.
-func (jwt *gcpAuthJwt) createSignedJwtWithRetry(l lane.Lane, maxRetries int) (signedJwt string, err error) {
+func (jwt *gcpAuthJwt) createSignedJwtWithRetry(l lane.Lane) (signedJwt string, err error) {
+	policy := jwt.cfg.signRetry
+	maxRetries := policy.retries()
 
 	b := backoff.NewExponentialBackOff()
+	if policy.InitialInterval > 0 {
+		b.InitialInterval = policy.InitialInterval
+	}
+	if policy.MaxInterval > 0 {
+		b.MaxInterval = policy.MaxInterval
+	}
+	if policy.MaxElapsedTime > 0 {
+		b.MaxElapsedTime = policy.MaxElapsedTime
+	}
+	b.Reset()
+
+	// record the delays the backoff chose, for diagnosing flaky signing
+	var delays []time.Duration
+	notify := func(err error, delay time.Duration) {
+		delays = append(delays, delay)
+		l.Warnf("jwt signing attempt %d failed, retrying in %s: %v", len(delays), delay, err)
+		if jwt.cfg.signNotify != nil {
+			jwt.cfg.signNotify(len(delays), err, delay)
+		}
+	}
 
-	err = backoff.Retry(func() error {
+	// WithMaxRetries treats zero as no limit, so no retries needs StopBackOff
+	rb := newRetryAfterBackOff(b)
+	var policyBackOff backoff.BackOff = &backoff.StopBackOff{}
+	if maxRetries > 0 {
+		policyBackOff = backoff.WithMaxRetries(rb, uint64(maxRetries))
+	}
+	err = backoff.RetryNotify(func() error {
 		signedJwt, err = jwt.createSignedJwt(l)
-		return err
-	}, backoff.WithMaxRetries(b, uint64(maxRetries)))
+		jwt.cfg.stats.recordSign(err)
+		return permanentUnless(jwt.cfg.retryable, rb.record(err))
+	}, backoff.WithContext(policyBackOff, l), notify)
 
 	if err != nil {
-		l.Errorf("unable to sign JWT after %d retries: %v", maxRetries, err)
+		l.Errorf("unable to sign JWT after %d retries (delays %v): %v", maxRetries, delays, err)
 		return
 	}
 
@@ -76,17 +165,39 @@ func (jwt *gcpAuthJwt) createSignedJwt(l lane.Lane) (signedJwt string, err error
 	hc := &http.Client{
 		Timeout: defaultClient.Timeout,
 		Transport: &oauth2.Transport{
-			Source: tokenSrc,
+			Source: taggedTokenSource{src: tokenSrc},
 			Base:   defaultClient.Transport,
 		},
+		CheckRedirect: jwt.checkRedirect,
+	}
+	jwt.cfg.debug.recordAccessToken(l, defaultClient, tokenSrc, jwt.cfg.scopes, jwt.cfg.maxResponseBytes)
+
+	sub := saEmail
+	if jwt.cfg.jwtSubject == JwtSubjectUniqueId {
+		if sub, err = jwt.getSaUniqueId(l, hc, saEmail); err != nil {
+			return
+		}
+	}
+
+	// extra claims come first so they can't replace the required ones
+	claims := make(map[string]any, len(jwt.cfg.extraClaims)+4)
+	for k, v := range jwt.cfg.extraClaims {
+		claims[k] = v
+	}
+	claims["aud"] = jwt.cfg.audience(jwt.cfg.role)
+	claims["sub"] = sub
+	claims["exp"] = jwt.expiration(time.Now())
+	if jwt.cfg.jwtNonce {
+		nonce := make([]byte, 16)
+		if _, err = rand.Read(nonce); err != nil {
+			l.Errorf("jwt nonce error: %v", err)
+			return
+		}
+		claims["jti"] = hex.EncodeToString(nonce)
 	}
 
 	var claim []byte
-	claim, err = json.Marshal(map[string]interface{}{
-		"aud": "vault/" + jwt.cfg.role,
-		"sub": saEmail,
-		"exp": time.Now().UTC().Add(kJwtTokenTimeoutMins * time.Minute).Unix(),
-	})
+	claim, err = json.Marshal(claims)
 	if err != nil {
 		l.Errorf("inner jwt marshalling error: %v", err)
 		return
@@ -102,21 +213,31 @@ func (jwt *gcpAuthJwt) createSignedJwt(l lane.Lane) (signedJwt string, err error
 	}
 
 	reqBody := []byte(`{"payload":` + string(payload) + "}")
-	url := fmt.Sprintf("%s/projects/-/serviceAccounts/%s:signJwt", kGcpIamCredentialsUrl, url.PathEscape(saEmail))
+	url := fmt.Sprintf("%s/projects/-/serviceAccounts/%s:signJwt", jwt.signJwtBaseUrl(), url.PathEscape(saEmail))
 
 	l.Tracef("request url: %s", url)
 	l.Tracef("request body: %s", string(reqBody))
 
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(l, http.MethodPost, url, bytes.NewBuffer(reqBody)); err != nil {
+		l.Errorf("error creating gcp oauth2 request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
 	var resp *http.Response
-	resp, err = hc.Post(url, "application/json", bytes.NewBuffer(reqBody))
-	if err != nil {
-		l.Errorf("error posting to gcp oauth2: %v", err)
+	if resp, err = hc.Do(req); err != nil {
+		if errors.Is(err, ErrGCPTokenSource) {
+			l.Errorf("can't get gcp access token for the signing request: %v", err)
+		} else {
+			l.Errorf("error posting to gcp oauth2: %v", err)
+		}
 		return
 	}
 	defer resp.Body.Close()
 
 	var body []byte
-	if body, err = io.ReadAll(resp.Body); err != nil {
+	if body, err = readAllLimited(resp.Body, jwt.cfg.maxResponseBytes); err != nil {
 		l.Errorf("error receiving gcp oauth2 response: %v", err)
 		return
 	}
@@ -130,9 +251,10 @@ func (jwt *gcpAuthJwt) createSignedJwt(l lane.Lane) (signedJwt string, err error
 
 	jwtErr, exists := data["error"]
 	if exists {
-		m := jwtErr.(map[string]any)
-		l.Errorf("error requesting jwt signing %d %s %s", int(m["code"].(float64)), m["status"].(string), m["message"].(string))
-		err = errors.New(m["message"].(string))
+		iamErr := newGCPIAMError(jwtErr, resp.StatusCode)
+		iamErr.retryAfter, _ = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		l.Errorf("error requesting jwt signing %d %s %s", iamErr.Code, iamErr.Status, iamErr.Message)
+		err = iamErr
 		return
 	}
 
@@ -145,17 +267,46 @@ func (jwt *gcpAuthJwt) createSignedJwt(l lane.Lane) (signedJwt string, err error
 	return
 }
 
+// expiration computes the "exp" claim in whole seconds. The seconds can be
+// rounded up and padded so that truncation and latency don't cause Vault to
+// see an already-expired JWT.
+func (jwt *gcpAuthJwt) expiration(now time.Time) int64 {
+	exp := now.UTC().Add(jwt.cfg.jwtTtl + jwt.cfg.expPad)
+	expSecs := exp.Unix()
+	if jwt.cfg.expCeil && exp.Nanosecond() > 0 {
+		expSecs++
+	}
+	return expSecs
+}
+
+// signJwtBaseUrl picks the API for the signJwt request. Both APIs accept the
+// same request body and respond with a signedJwt field.
+func (jwt *gcpAuthJwt) signJwtBaseUrl() string {
+	if jwt.cfg.signJwtApi == SignJwtIamLegacy {
+		return kGcpIamUrl
+	}
+	return kGcpIamCredentialsUrl
+}
+
 // The current running context provides a Kubernetes Service Account (ksa)
 // which maps to a Google Service Account (gsa) via Google's Workload Identity.
 // If that mechanism isn't set up properly, the code here will fall back to
 // the default gsa.
 func (jwt *gcpAuthJwt) getSaInfo(l lane.Lane) (saEmail string, tokenSrc oauth2.TokenSource, err error) {
-	l.Tracef("vault-auth-gcp: requesting GCP default credentials at %s", kGcpAuthUrl)
+	l.Tracef("vault-auth-gcp: requesting GCP default credentials for %v", jwt.cfg.scopes)
 
-	var creds *google.Credentials
-	if creds, err = google.FindDefaultCredentials(l, kGcpAuthUrl); err != nil {
-		l.Errorf("unable to find default google credentials for service account: %v", err)
-		return
+	// credentials supplied by the caller take the place of discovery
+	creds := jwt.cfg.credentials
+	if creds == nil && jwt.cfg.adcFile != "" {
+		if creds, err = jwt.readAdcFile(l); err != nil {
+			return
+		}
+	}
+	if creds == nil {
+		if creds, err = jwt.cfg.findCredentials(l, jwt.cfg.scopes...); err != nil {
+			l.Errorf("unable to find default google credentials for service account: %v", err)
+			return
+		}
 	}
 
 	if saEmail, err = jwt.parseCredentials(l, creds); err != nil {
@@ -173,6 +324,63 @@ func (jwt *gcpAuthJwt) getSaInfo(l lane.Lane) (saEmail string, tokenSrc oauth2.T
 	return
 }
 
+// readAdcFile loads the credentials in the configured application default
+// credentials file
+func (jwt *gcpAuthJwt) readAdcFile(l lane.Lane) (creds *google.Credentials, err error) {
+	var content []byte
+	if content, err = os.ReadFile(jwt.cfg.adcFile); err != nil {
+		l.Errorf("unable to read google credentials file %s: %v", jwt.cfg.adcFile, err)
+		return
+	}
+
+	if creds, err = google.CredentialsFromJSON(l, content, jwt.cfg.scopes...); err != nil {
+		l.Errorf("unable to parse google credentials file %s: %v", jwt.cfg.adcFile, err)
+		return
+	}
+	return
+}
+
+// Looks up the numeric unique ID of the service account via the IAM API.
+// see https://cloud.google.com/iam/docs/reference/rest/v1/projects.serviceAccounts/get
+func (jwt *gcpAuthJwt) getSaUniqueId(l lane.Lane, hc *http.Client, saEmail string) (uniqueId string, err error) {
+	url := fmt.Sprintf("%s/projects/-/serviceAccounts/%s", kGcpIamUrl, url.PathEscape(saEmail))
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(l, http.MethodGet, url, nil); err != nil {
+		l.Errorf("error creating service account request: %v", err)
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = hc.Do(req); err != nil {
+		l.Errorf("error requesting service account %s: %v", saEmail, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	var body []byte
+	if body, err = readAllLimited(resp.Body, jwt.cfg.maxResponseBytes); err != nil {
+		l.Errorf("error receiving service account response: %v", err)
+		return
+	}
+
+	var data map[string]any
+	if err = json.Unmarshal(body, &data); err != nil {
+		l.Errorf("error parsing service account response: %v", err)
+		return
+	}
+
+	uniqueId, _ = data["uniqueId"].(string)
+	if uniqueId == "" {
+		l.Errorf("service account %s has no unique id: %s", saEmail, string(body))
+		err = errors.New("service account unique id not available")
+		return
+	}
+
+	l.Tracef("vault-auth-gcp: service account unique id is %s", uniqueId)
+	return
+}
+
 // see https://cloud.google.com/compute/docs/metadata/overview
 func (jwt *gcpAuthJwt) getDefaultSaEmail(l lane.Lane) (saEmail string, err error) {
 	saEmail, err = metadata.EmailWithContext(l, "")
@@ -189,19 +397,35 @@ func (jwt *gcpAuthJwt) getDefaultSaEmail(l lane.Lane) (saEmail string, err error
 }
 
 // worker that pulls out client e-mail address from credentials provided by
-// the Google client SDK
+// the Google client SDK; service_account keys carry it as client_email, and
+// impersonating credentials in their impersonation url. An empty e-mail
+// (e.g., for credentials from the metadata server) means the caller must
+// find it another way.
 func (jwt *gcpAuthJwt) parseCredentials(l lane.Lane, creds *google.Credentials) (email string, err error) {
 	if len(creds.JSON) > 0 {
-		var data map[string]string
+		var data map[string]any
 		if err = json.Unmarshal(creds.JSON, &data); err != nil {
-			l.Errorf("unable to parse credentials: %v", err)
-			l.Info("credentials: %s", string(creds.JSON))
+			// the credentials can hold a private key, so they are never logged
+			l.Errorf("unable to parse credentials (%d bytes): %v", len(creds.JSON), err)
 			return
 		}
 
-		email = data["client_email"]
-		if email == "" {
-			l.Debug("vault-auth-gcp: client_email is empty")
+		// the identity is found in a different place for each shape
+		credType, _ := data["type"].(string)
+		switch credType {
+		case "external_account", "impersonated_service_account":
+			return jwt.parseImpersonationUrl(l, credType, data)
+
+		case "authorized_user":
+			l.Errorf("vault-auth-gcp: user credentials can't sign jwts; impersonate a service account, e.g., gcloud auth application-default login --impersonate-service-account")
+			err = errors.New("authorized_user credentials have no service account")
+			return
+
+		default:
+			email, _ = data["client_email"].(string)
+			if email == "" {
+				l.Debugf("vault-auth-gcp: %s credentials have no client_email", credType)
+			}
 		}
 	} else {
 		l.Debug("vault-auth-gcp: creds.JSON is empty")
@@ -210,15 +434,82 @@ func (jwt *gcpAuthJwt) parseCredentials(l lane.Lane, creds *google.Credentials)
 	return
 }
 
+// Workload Identity Federation credentials (type external_account) let a
+// workload outside of GCP act as a service account by impersonation, as do
+// impersonated_service_account credentials made by gcloud. Only the
+// impersonated service account can sign a JWT, so the e-mail is taken from the
+// impersonation URL, and credentials without one are rejected.
+// see https://cloud.google.com/iam/docs/workload-identity-federation
+func (jwt *gcpAuthJwt) parseImpersonationUrl(l lane.Lane, credType string, data map[string]any) (email string, err error) {
+	impersonationUrl, _ := data["service_account_impersonation_url"].(string)
+	if impersonationUrl == "" {
+		l.Errorf("vault-auth-gcp: %s credentials must impersonate a service account to sign jwts", credType)
+		err = fmt.Errorf("%s credentials have no service_account_impersonation_url", credType)
+		return
+	}
+
+	// the url ends with .../serviceAccounts/<email>:generateAccessToken
+	_, resource, found := strings.Cut(impersonationUrl, "/serviceAccounts/")
+	email, _, _ = strings.Cut(resource, ":")
+	if !found || email == "" {
+		l.Errorf("vault-auth-gcp: can't find service account in impersonation url %s", impersonationUrl)
+		err = errors.New("malformed service_account_impersonation_url")
+		return
+	}
+
+	if email, err = url.PathUnescape(email); err != nil {
+		l.Errorf("vault-auth-gcp: can't decode service account in impersonation url %s", impersonationUrl)
+		return
+	}
+
+	l.Tracef("vault-auth-gcp: %s credentials impersonate %s", credType, email)
+	return
+}
+
+// Governs redirects of the IAM requests. The oauth2 transport authorizes each
+// hop, so a followed redirect carries the GCP access token; for that reason a
+// redirect is refused if it downgrades https to http.
+func (jwt *gcpAuthJwt) checkRedirect(req *http.Request, via []*http.Request) error {
+	if !jwt.cfg.followRedirects {
+		return fmt.Errorf("redirect to %s not followed", req.URL.Redacted())
+	}
+	if via[0].URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("insecure redirect to %s refused", req.URL.Redacted())
+	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
+
 // Creates an http client for REST requests, with test hook possibility
 func (jwt *gcpAuthJwt) getHttpClient() *http.Client {
 	// allow test hook
 	if jwt.cfg.testClient != nil {
-		return jwt.cfg.testClient
+		if jwt.cfg.signTimeout <= 0 {
+			return jwt.cfg.testClient
+		}
+		hc := *jwt.cfg.testClient
+		hc.Timeout = jwt.cfg.signTimeout
+		return &hc
 	}
 	return &http.Client{
-		Transport: &http.Transport{
-			IdleConnTimeout: kJwtClientIdleTimeoutSecs * time.Second,
-		},
+		Transport: jwt.cfg.signTransport(),
+		Timeout:   jwt.cfg.signTimeout,
+	}
+}
+
+// signTransport makes the transport of the signer's connections to Google
+func (gcpcfg *gcpAuthConfig) signTransport() *http.Transport {
+	t := &http.Transport{
+		IdleConnTimeout: kJwtClientIdleTimeoutSecs * time.Second,
+	}
+	if gcpcfg.signDialTimeout > 0 {
+		t.DialContext = (&net.Dialer{Timeout: gcpcfg.signDialTimeout}).DialContext
+		t.TLSHandshakeTimeout = gcpcfg.signDialTimeout
+	}
+	if gcpcfg.forceHttp1 {
+		disableHttp2(t)
 	}
+	return t
 }
@@ -0,0 +1,224 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+type (
+	// roundTripFunc stands in for the Google APIs
+	roundTripFunc func(req *http.Request) (*http.Response, error)
+)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newTestGcpJwt makes a JWT signer whose requests are answered by rt, using
+// service account credentials that don't need discovery
+func newTestGcpJwt(t *testing.T, l lane.Lane, rt roundTripFunc, opts ...gcpAuthOption) *gcpAuthJwt {
+	auth := newGcpAuth(append([]gcpAuthOption{gcpWithTestClient(&http.Client{Transport: rt})}, opts...)...)
+	cfg, err := auth.getConfig(l, "my-role")
+	if err != nil {
+		t.Fatalf("can't make gcp config: %v", err)
+	}
+	gcpcfg := cfg.(gcpAuthConfig)
+	gcpcfg.credentials = testGcpCredentials()
+	return newGcpAuthJwt(&gcpcfg)
+}
+
+// testGcpCredentials are service account credentials that don't need
+// discovery, with a fixed access token
+func testGcpCredentials() *google.Credentials {
+	return &google.Credentials{
+		JSON:        []byte(`{"type":"service_account","client_email":"sa@proj.iam.gserviceaccount.com"}`),
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "gcp-access-token"}),
+	}
+}
+
+func jsonResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestCreateSignedJwt(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+
+	var claims map[string]any
+	jwt := newTestGcpJwt(t, l, func(req *http.Request) (*http.Response, error) {
+		if req.URL.String() != "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@proj.iam.gserviceaccount.com:signJwt" {
+			t.Errorf("unexpected signing url %s", req.URL)
+		}
+		if auth := req.Header.Get("Authorization"); auth != "Bearer gcp-access-token" {
+			t.Errorf("unexpected authorization %q", auth)
+		}
+
+		var body struct {
+			Payload string `json:"payload"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			t.Errorf("can't parse signing request: %v", err)
+		}
+		if err := json.Unmarshal([]byte(body.Payload), &claims); err != nil {
+			t.Errorf("can't parse jwt claims: %v", err)
+		}
+		return jsonResponse(req, http.StatusOK, `{"keyId":"k1","signedJwt":"signed.jwt.value"}`), nil
+	})
+
+	signedJwt, err := jwt.createSignedJwt(l)
+	if err != nil {
+		t.Fatalf("signing failed: %v", err)
+	}
+	if signedJwt != "signed.jwt.value" {
+		t.Errorf("unexpected signed jwt %q", signedJwt)
+	}
+	if claims["aud"] != "vault/my-role" {
+		t.Errorf("unexpected aud claim %v", claims["aud"])
+	}
+	if claims["sub"] != "sa@proj.iam.gserviceaccount.com" {
+		t.Errorf("unexpected sub claim %v", claims["sub"])
+	}
+}
+
+func TestCreateSignedJwtIamError(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+
+	jwt := newTestGcpJwt(t, l, func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(req, http.StatusForbidden,
+			`{"error":{"code":403,"status":"PERMISSION_DENIED","message":"iam.serviceAccounts.signJwt denied"}}`), nil
+	})
+
+	_, err := jwt.createSignedJwt(l)
+	iamErr, isIamErr := err.(*GCPIAMError)
+	if !isIamErr {
+		t.Fatalf("expected a GCPIAMError, got %v", err)
+	}
+	if iamErr.HTTPStatusCode() != http.StatusForbidden {
+		t.Errorf("expected status 403, got %d", iamErr.HTTPStatusCode())
+	}
+}
+
+func TestGetHttpClientTestHook(t *testing.T) {
+	client := &http.Client{}
+	auth := newGcpAuth(gcpWithTestClient(client))
+	cfg, err := auth.getConfig(lane.NewTestingLane(context.Background()), "my-role")
+	if err != nil {
+		t.Fatalf("can't make gcp config: %v", err)
+	}
+	gcpcfg := cfg.(gcpAuthConfig)
+
+	if hc := newGcpAuthJwt(&gcpcfg).getHttpClient(); hc != client {
+		t.Error("the test client wasn't used")
+	}
+}
+
+func TestSignerForceHTTP1(t *testing.T) {
+	mv, protos := newHttp2MockVault(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(mv.srv.Certificate())
+
+	for _, force := range []bool{false, true} {
+		jwt := newGcpAuthJwt(&gcpAuthConfig{forceHttp1: force})
+		hc := jwt.getHttpClient()
+		tr := hc.Transport.(*http.Transport)
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		tr.TLSClientConfig.RootCAs = pool
+		// a custom TLS config alone turns off HTTP/2, which mustn't stand
+		// in for the option doing it
+		tr.ForceAttemptHTTP2 = true
+
+		resp, err := hc.Get(mv.srv.URL + "/v1/sys/health")
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+
+		expected := 2
+		if force {
+			expected = 1
+		}
+		if proto := <-protos; proto != expected {
+			t.Errorf("force=%t: expected HTTP/%d, got HTTP/%d", force, expected, proto)
+		}
+	}
+}
+
+func TestCreateSignedJwtRetryCount(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+
+	cases := []struct {
+		maxRetries int
+		attempts   int32
+	}{
+		{0, kSignMaxRetries + 1}, // the default
+		{2, 3},
+		{SignNoRetries, 1},
+	}
+	for _, c := range cases {
+		var attempts atomic.Int32
+		jwt := newTestGcpJwt(t, l, func(req *http.Request) (*http.Response, error) {
+			attempts.Add(1)
+			return jsonResponse(req, http.StatusServiceUnavailable,
+				`{"error":{"code":503,"status":"UNAVAILABLE","message":"try again"}}`), nil
+		}, gcpWithRetryable(DefaultRetryable))
+		jwt.cfg.signRetry = SignRetryPolicy{
+			MaxRetries:      c.maxRetries,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     time.Millisecond,
+		}
+
+		if _, err := jwt.createSignedJwtWithRetry(l); err == nil {
+			t.Fatalf("max retries %d: expected signing to fail", c.maxRetries)
+		}
+		if n := attempts.Load(); n != c.attempts {
+			t.Errorf("max retries %d: expected %d attempts, got %d", c.maxRetries, c.attempts, n)
+		}
+	}
+}
+
+func TestJwtExpiration(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	now := time.Date(2026, 10, 15, 8, 0, 0, 400_000_000, time.UTC)
+
+	cases := []struct {
+		ceil bool
+		pad  time.Duration
+		exp  int64
+	}{
+		{false, 0, now.Unix() + 60},
+		{true, 0, now.Unix() + 61},
+		{false, 2 * time.Second, now.Unix() + 62},
+		{true, 2 * time.Second, now.Unix() + 63},
+		// a pad that completes the second leaves nothing to round
+		{true, 600 * time.Millisecond, now.Unix() + 61},
+	}
+	for _, c := range cases {
+		jwt := newTestGcpJwt(t, l, signJwtOk, func(cfg *gcpAuthConfig) {
+			cfg.expCeil = c.ceil
+			cfg.expPad = c.pad
+		})
+		if exp := jwt.expiration(now); exp != c.exp {
+			t.Errorf("ceil %v pad %v: expected exp %d, got %d", c.ceil, c.pad, c.exp, exp)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build nogcp
+
+package vaulttoken
+
+import (
+	"fmt"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// The nogcp build tag excludes GCP auth and its Google dependencies. These
+// placeholders keep the core of the package compiling without it.
+
+type (
+	gcpAuthConfig struct {
+	}
+
+	gcpAuthOption func(cfg *gcpAuthConfig)
+
+	noGcpAuth struct {
+	}
+)
+
+var errGcpExcluded = fmt.Errorf("%w: gcp auth is excluded from this build (nogcp build tag)", ErrNoAuthConfigured)
+
+// newDefaultAuth provides an auth that reports GCP auth is unavailable
+func newDefaultAuth(vo *vaultOptions, stats *connStats) VaultAuth {
+	return &noGcpAuth{}
+}
+
+func (auth *noGcpAuth) authMethod() string {
+	return AuthMethodGcp
+}
+
+func (auth *noGcpAuth) getConfig(l lane.Lane, vaultRole string) (cfg VaultAuthConfig, err error) {
+	err = errGcpExcluded
+	return
+}
+
+func (auth *noGcpAuth) newVaultToken(l lane.Lane, authCfg VaultAuthConfig, client *vaultapi.Client) (token VaultToken, err error) {
+	err = errGcpExcluded
+	return
+}
@@ -0,0 +1,41 @@
+//go:build nogcp
+
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestNoGcpConstructor(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	// without a static token, a role selects GCP auth, which isn't built
+	_, err := NewVaultClient(l, mv.srv.URL, "", "", "", "my-role")
+	if !errors.Is(err, errGcpExcluded) || !errors.Is(err, ErrNoAuthConfigured) {
+		t.Errorf("expected the gcp excluded error, got %v", err)
+	}
+}
+
+func TestNoGcpDependencies(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not available")
+	}
+
+	out, err := exec.Command(goTool, "list", "-deps", "-tags", "nogcp", ".").Output()
+	if err != nil {
+		t.Fatalf("go list failed: %v", err)
+	}
+	for _, pkg := range strings.Fields(string(out)) {
+		if strings.HasPrefix(pkg, "cloud.google.com/") || strings.HasPrefix(pkg, "golang.org/x/oauth2/google") || strings.HasPrefix(pkg, "google.golang.org/api") {
+			t.Errorf("the nogcp build depends on %s", pkg)
+		}
+	}
+}
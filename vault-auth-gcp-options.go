@@ -0,0 +1,295 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"net/http"
+	"time"
+)
+
+type (
+	// gcpAuthOption adjusts the GCP auth config built by gcpAuth.getConfig
+	gcpAuthOption func(cfg *gcpAuthConfig)
+
+	// JwtSubject selects the service account identifier placed in the "sub"
+	// claim of the GCP login JWT.
+	JwtSubject int
+
+	// AudienceFunc maps the Vault role to the "aud" claim of the GCP login JWT.
+	AudienceFunc func(role string) string
+
+	// SignJwtApi selects the Google API used to have the service account
+	// sign the Vault login JWT.
+	SignJwtApi int
+
+	// SignRetryPolicy controls the retries of the GCP JWT signing request. A
+	// zero MaxRetries keeps the default of 5 retries, and a negative one
+	// disables retries. A zero interval or elapsed time keeps the
+	// exponential backoff's default (500ms initial interval, 1m max
+	// interval, 15m max elapsed time).
+	SignRetryPolicy struct {
+		MaxRetries      int
+		InitialInterval time.Duration
+		MaxInterval     time.Duration
+		MaxElapsedTime  time.Duration
+	}
+
+	// GcpAuthType selects the kind of Vault GCP login.
+	GcpAuthType int
+)
+
+// SignNoRetries is the SignRetryPolicy MaxRetries that disables retries.
+const SignNoRetries = -1
+
+const (
+	// SignJwtIamCredentials uses iamcredentials.googleapis.com (the default).
+	SignJwtIamCredentials SignJwtApi = iota
+	// SignJwtIamLegacy uses the older iam.googleapis.com signJwt method, for
+	// environments that only expose that API.
+	SignJwtIamLegacy
+)
+
+const (
+	// GcpAuthIam logs in with a JWT signed by the service account through
+	// the IAM signJwt API (the default).
+	GcpAuthIam GcpAuthType = iota
+	// GcpAuthGce logs in with the instance identity token of the GCE VM,
+	// from the metadata server, for VMs without signJwt permission.
+	GcpAuthGce
+)
+
+const (
+	// JwtSubjectEmail uses the service account e-mail (the default).
+	JwtSubjectEmail JwtSubject = iota
+	// JwtSubjectUniqueId uses the service account's numeric unique ID, for
+	// Vault roles bound by ID.
+	JwtSubjectUniqueId
+)
+
+// WithSignJwtApi chooses the Google endpoint that signs the GCP login JWT.
+func WithSignJwtApi(api SignJwtApi) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.signJwtApi = api
+	})
+}
+
+// WithGCPScopes replaces the OAuth2 scopes requested for the GCP credentials
+// that call the signJwt API. The default is the cloud-platform scope; some
+// VPC-SC perimeters need additional scopes.
+func WithGCPScopes(scopes ...string) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		if len(scopes) > 0 {
+			cfg.scopes = scopes
+		}
+	})
+}
+
+// WithJwtSubject chooses the "sub" claim of the GCP login JWT.
+func WithJwtSubject(subject JwtSubject) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.jwtSubject = subject
+	})
+}
+
+// WithSignRetryNotify registers a callback that observes the backoff schedule
+// of GCP JWT signing retries.
+func WithSignRetryNotify(notify RetryNotifyFunc) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.signNotify = notify
+	})
+}
+
+// WithSignerRedirects controls whether the GCP IAM requests follow http
+// redirects. Redirects are followed by default, except from https to http.
+func WithSignerRedirects(follow bool) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.followRedirects = follow
+	})
+}
+
+// WithJwtTTL sets the lifetime of the GCP login JWT, allowing for clock skew
+// between the workload and Vault. The default is 1 minute, and the limit is
+// 15 minutes; the Vault role's max_jwt_exp must allow it too.
+func WithJwtTTL(ttl time.Duration) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.jwtTtl = ttl
+	})
+}
+
+// WithJwtClaims adds claims to the GCP login JWT, e.g., to satisfy a Vault
+// role's bound_claims. The aud, sub and exp claims can't be overridden.
+func WithJwtClaims(claims map[string]any) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.extraClaims = claims
+	})
+}
+
+// WithJwtNonce adds a random jti claim to each GCP login JWT, making every
+// signed JWT unique for audit setups that track replay. Vault doesn't
+// require it.
+func WithJwtNonce(nonce bool) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.jwtNonce = nonce
+	})
+}
+
+// WithSignRetryPolicy replaces the retry policy of the GCP JWT signing
+// request, which by default retries 5 times with exponential backoff. Use
+// SignNoRetries as the MaxRetries to sign only once.
+func WithSignRetryPolicy(policy SignRetryPolicy) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.signRetry = policy
+	})
+}
+
+// retries is the number of retries the policy allows
+func (p SignRetryPolicy) retries() int {
+	switch {
+	case p.MaxRetries == 0:
+		return kSignMaxRetries
+	case p.MaxRetries < 0:
+		return 0
+	}
+	return p.MaxRetries
+}
+
+// WithJwtExpRounding adjusts the "exp" claim of the GCP login JWT: ceil rounds
+// the expiration up to the next whole second instead of truncating, and pad
+// extends it, reducing premature-expiry rejections. The result must stay
+// within the JWT lifetime the Vault role allows.
+func WithJwtExpRounding(ceil bool, pad time.Duration) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.expCeil = ceil
+		cfg.expPad = pad
+	})
+}
+
+// WithAudienceFunc replaces the mapping of Vault role to JWT audience, which
+// is "vault/<role>" by default.
+func WithAudienceFunc(audience AudienceFunc) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		if audience != nil {
+			cfg.audience = audience
+		}
+	})
+}
+
+// WithADCFile loads the GCP credentials from an application default
+// credentials file, such as one made by
+// "gcloud auth application-default login", instead of discovering them. This
+// lets a developer switch identities without changing the environment.
+func WithADCFile(path string) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.adcFile = path
+	})
+}
+
+// WithSignTimeout bounds each request the GCP signer makes to Google, such as
+// the signJwt call. By default, there is no bound beyond the lane's context.
+// Vault requests are bounded separately, by WithTimeout.
+func WithSignTimeout(timeout time.Duration) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.signTimeout = timeout
+	})
+}
+
+// WithSignDialTimeout bounds the connect and TLS handshake of each
+// connection the GCP signer opens to Google, separately from the overall
+// request bound of WithSignTimeout. By default, there is no bound beyond
+// the lane's context.
+func WithSignDialTimeout(timeout time.Duration) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.signDialTimeout = timeout
+	})
+}
+
+// WithAudienceCheck makes each GCP login first read the Vault role and
+// confirm the JWT audience is among the role's bound_audiences, to catch an
+// audience mismatch before signing. The check is skipped when the role
+// can't be read, e.g., because the current token lacks permission.
+func WithAudienceCheck() VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.checkAudience = true
+	})
+}
+
+// WithGCPAuthType chooses between IAM (the default) and GCE GCP logins.
+func WithGCPAuthType(authType GcpAuthType) VaultOption {
+	return withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.authType = authType
+	})
+}
+
+// WithGCPAuthPath sets where the GCP auth method is mounted in Vault, e.g.,
+// "auth/gcp-prod". The default is "auth/gcp".
+func WithGCPAuthPath(path string) VaultOption {
+	return withGcpOption(gcpWithAuthPath(path))
+}
+
+// withGcpOption makes a VaultOption that passes a GCP auth option through
+// to gcpAuth.getConfig
+func withGcpOption(opt gcpAuthOption) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.gcpOpts = append(opts.gcpOpts, opt)
+	}
+}
+
+func gcpWithAuthPath(authPath string) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.authPath = authPath
+	}
+}
+
+func gcpWithForceHttp1(force bool) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.forceHttp1 = force
+	}
+}
+
+func gcpWithTestClient(client *http.Client) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.testClient = client
+	}
+}
+
+func gcpWithTransport(transport VaultTransport) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.transport = transport
+	}
+}
+
+func gcpWithRequestedTtl(ttl time.Duration) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.requestedTtl = ttl
+	}
+}
+
+func gcpWithStats(stats *connStats) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.stats = stats
+	}
+}
+
+func gcpWithMaxResponseBytes(n int64) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.maxResponseBytes = n
+	}
+}
+
+func gcpWithRetryable(retryable RetryableFunc) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.retryable = retryable
+	}
+}
+
+func gcpWithClock(c clock) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.clock = c
+	}
+}
+
+func gcpWithLoginTemplate(template string) gcpAuthOption {
+	return func(cfg *gcpAuthConfig) {
+		cfg.loginTemplate = template
+	}
+}
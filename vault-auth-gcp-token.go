@@ -1,135 +1,83 @@
+//go:build !nogcp
+
 package vaulttoken
 
 import (
 	"fmt"
-	"time"
 
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/jimsnab/go-lane"
 )
 
-type (
-	gcpAuthToken struct {
-		token      *vaultapi.Secret
-		expiration time.Time
-		cfg        *gcpAuthConfig
-		client     *vaultapi.Client
-	}
-)
-
-// newGcpAuthToken returns a struct for a class of functions that perform a Vault
-// login with a Google Service Account (gsa) signed JWT, and maintain the token
-func newGcpAuthToken(gcpcfg *gcpAuthConfig, client *vaultapi.Client) *gcpAuthToken {
-	return &gcpAuthToken{
-		cfg:    gcpcfg,
-		client: client,
-	}
+// newGcpAuthToken returns a VaultToken that performs a Vault login with a
+// Google Service Account (gsa) signed JWT, and maintains the token
+func newGcpAuthToken(gcpcfg *gcpAuthConfig, client *vaultapi.Client) *loginToken {
+	loginPath := expandLoginPath(gcpcfg.loginTemplate, gcpcfg.authPath, gcpcfg.role)
+	lt := newLoginToken(client, gcpcfg.transport, loginPath, func(l lane.Lane) (jsonData map[string]any, err error) {
+		return gcpLoginCredentials(l, gcpcfg, client)
+	})
+	lt.requestedTtl = gcpcfg.requestedTtl
+	lt.retryable = gcpcfg.retryable
+	lt.clock = gcpcfg.clock
+	return lt
 }
 
-// getToken performs a fresh login to Vault using a gsa-signed JWT
-func (gat *gcpAuthToken) getToken(l lane.Lane) (token *vaultapi.Secret, err error) {
-	if gat.token == nil {
-		jwt := newGcpAuthJwt(gat.cfg)
-
-		var signedJwt string
-		if signedJwt, err = jwt.createSignedJwtWithRetry(l, 5); err != nil {
-			l.Errorf("can't get signed jwt token for auth: %v", err)
-			return
-		}
-
-		jsonData := map[string]any{
-			"role": gat.cfg.role,
-			"jwt":  signedJwt,
-		}
-
-		// capture time before the login request
-		now := time.Now()
-
-		var resp *vaultapi.Secret
-		if resp, err = gat.client.Logical().Write(gat.cfg.authPath+"/login", jsonData); err != nil {
-			l.Errorf("vault login request error: %v", err)
+// gcpLoginCredentials makes the login request body with a fresh gsa-signed JWT,
+// or for a gce login, the instance identity token
+func gcpLoginCredentials(l lane.Lane, gcpcfg *gcpAuthConfig, client *vaultapi.Client) (jsonData map[string]any, err error) {
+	if gcpcfg.checkAudience {
+		if err = checkGcpAudience(l, gcpcfg, client); err != nil {
 			return
 		}
+	}
 
-		var tokenTtl time.Duration
-		if tokenTtl, err = resp.TokenTTL(); err != nil {
-			l.Errorf("vault token ttl error: %v", err)
+	var signedJwt string
+	if gcpcfg.authType == GcpAuthGce {
+		if signedJwt, err = getGceIdentityToken(l, gcpcfg); err != nil {
+			l.Errorf("can't get instance identity token for auth: %v", err)
+			err = fmt.Errorf("%w: %w", ErrJWTSigningFailed, err)
 			return
 		}
-
-		gat.token = resp
-		gat.expiration = now.Add(tokenTtl)
-	}
-
-	token = gat.token
-	return
-}
-
-// isExpired looks at the current time and indicates if the token has expired. A nil
-// token is considered expired.
-func (gat *gcpAuthToken) isExpired(l lane.Lane) (expired bool, err error) {
-	if gat.token == nil {
-		expired = true
 	} else {
-		expired = time.Now().After(gat.expiration)
-	}
-	return
-}
-
-// isRevoked asks Vault to look up the token, and if any error occurs, the token is
-// considered revoked. A nil token is also considered revoked.
-func (gat *gcpAuthToken) isRevoked(l lane.Lane) (revoked bool, err error) {
-	if gat.token == nil {
-		revoked = true
-	} else {
-		var client *vaultapi.Client
-		if client, err = gat.client.Clone(); err != nil {
-			l.Errorf("can't clone vault api client to check revocation", err)
+		jwt := newGcpAuthJwt(gcpcfg)
+		if signedJwt, err = jwt.createSignedJwtWithRetry(l); err != nil {
+			l.Errorf("can't get signed jwt token for auth: %v", err)
+			err = fmt.Errorf("%w: %w", ErrJWTSigningFailed, err)
 			return
 		}
-		client.SetToken(gat.token.Auth.ClientToken)
+	}
+	gcpcfg.debug.recordSignedJwt(signedJwt)
 
-		_, testErr := client.Auth().Token().LookupSelfWithContext(l)
-		revoked = (testErr == nil)
+	jsonData = map[string]any{
+		"role": gcpcfg.role,
+		"jwt":  signedJwt,
 	}
 	return
 }
 
-// refresh asks Vault to extend the life of the token, and suggests a number of
-// seconds to add via nextTtlInSeconds. Vault doesn't have to use the suggested
-// new TTL.
-func (gat *gcpAuthToken) refresh(l lane.Lane, nextTtlInSeconds int) (err error) {
-	if gat.token == nil {
-		err = fmt.Errorf("can't refresh nil token")
+// checkGcpAudience confirms the role's bound_audiences admit the JWT
+// audience, if the role can be read
+func checkGcpAudience(l lane.Lane, gcpcfg *gcpAuthConfig, client *vaultapi.Client) (err error) {
+	rolePath := gcpcfg.authPath + "/role/" + gcpcfg.role
+	secret, readErr := client.Logical().ReadWithContext(l, rolePath)
+	if readErr != nil || secret == nil {
+		l.Debugf("vault-auth-gcp: skipping audience check, can't read %s: %v", rolePath, readErr)
 		return
 	}
 
-	var token *vaultapi.Secret
-	if token, err = gat.client.Auth().Token().RenewSelfWithContext(l, nextTtlInSeconds); err != nil {
-		l.Errorf("can't refresh vault api token: %v", err)
+	bound, _ := secret.Data["bound_audiences"].([]any)
+	if len(bound) == 0 {
 		return
 	}
 
-	var tokenTtl time.Duration
-	if tokenTtl, err = token.TokenTTL(); err != nil {
-		l.Errorf("vault token refresh ttl error: %v", err)
-		return
-	}
-
-	gat.expiration = time.Now().Add(tokenTtl)
-	return
-}
-
-// revoke asks Vault to discontinue use of the current token. A new login is required
-// upon success.
-func (gat *gcpAuthToken) revoke(l lane.Lane) (err error) {
-	if gat.token != nil {
-		if err = gat.client.Auth().Token().RevokeSelfWithContext(l, ""); err != nil {
-			l.Errorf("revoke vault token error: %v", err)
+	audience := gcpcfg.audience(gcpcfg.role)
+	for _, b := range bound {
+		if s, _ := b.(string); s == audience {
 			return
 		}
-
-		gat.token = nil
 	}
+
+	err = fmt.Errorf("jwt audience %q is not among the bound_audiences %v of %s", audience, bound, rolePath)
+	l.Errorf("vault-auth-gcp: %v", err)
 	return
 }
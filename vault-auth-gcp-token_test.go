@@ -0,0 +1,88 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+type (
+	// failingTokenSource stands in for credentials that can't refresh
+	failingTokenSource struct{}
+)
+
+func (failingTokenSource) Token() (*oauth2.Token, error) {
+	return nil, errors.New("refresh token revoked")
+}
+
+func TestGcpLoginErrJWTSigningFailed(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+
+	jwt := newTestGcpJwt(t, l, func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(req, http.StatusForbidden,
+			`{"error":{"code":403,"status":"PERMISSION_DENIED","message":"iam.serviceAccounts.signJwt denied"}}`), nil
+	}, gcpWithRetryable(DefaultRetryable))
+
+	_, err := gcpLoginCredentials(l, jwt.cfg, nil)
+	if !errors.Is(err, ErrJWTSigningFailed) {
+		t.Errorf("expected ErrJWTSigningFailed, got %v", err)
+	}
+	var iamErr *GCPIAMError
+	if !errors.As(err, &iamErr) {
+		t.Errorf("expected the IAM error in the chain, got %v", err)
+	}
+	if errors.Is(err, ErrGCPTokenSource) {
+		t.Errorf("an IAM refusal isn't a token source failure: %v", err)
+	}
+}
+
+func TestGcpLoginErrGCPTokenSource(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+
+	jwt := newTestGcpJwt(t, l, func(req *http.Request) (*http.Response, error) {
+		t.Error("google was called without an access token")
+		return jsonResponse(req, http.StatusInternalServerError, `{}`), nil
+	}, gcpWithRetryable(func(err error) bool { return false }))
+	jwt.cfg.credentials.TokenSource = failingTokenSource{}
+
+	_, err := gcpLoginCredentials(l, jwt.cfg, nil)
+	if !errors.Is(err, ErrGCPTokenSource) || !errors.Is(err, ErrJWTSigningFailed) {
+		t.Errorf("expected ErrGCPTokenSource within ErrJWTSigningFailed, got %v", err)
+	}
+}
+
+func TestGcpLoginTokenSourceFailure(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	expired := withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.credentials = &google.Credentials{
+			JSON:        testGcpCredentials().JSON,
+			TokenSource: failingTokenSource{},
+		}
+	})
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		t.Error("google was called without an access token")
+		return signJwtOk(req)
+	}, expired, WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}))
+
+	// the failure names the token source, not the IAM call
+	_, err := vcc.GetApiInterface(l)
+	if !errors.Is(err, ErrGCPTokenSource) {
+		t.Errorf("expected ErrGCPTokenSource, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "refresh token revoked") {
+		t.Errorf("the token source's reason was lost: %v", err)
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no vault login, got %d", n)
+	}
+}
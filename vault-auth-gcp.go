@@ -1,35 +1,172 @@
+//go:build !nogcp
+
 package vaulttoken
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"slices"
+	"time"
 
 	vaultapi "github.com/hashicorp/vault/api"
 	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2/google"
 )
 
 type (
 	gcpAuthConfig struct {
-		role       string
-		authPath   string
-		testClient *http.Client
+		role             string
+		authPath         string
+		signJwtApi       SignJwtApi
+		forceHttp1       bool
+		scopes           []string
+		jwtSubject       JwtSubject
+		signNotify       RetryNotifyFunc
+		transport        VaultTransport
+		debug            *gcpAuthDebug
+		followRedirects  bool
+		expCeil          bool
+		expPad           time.Duration
+		audience         AudienceFunc
+		loginTemplate    string
+		testClient       *http.Client
+		requestedTtl     time.Duration
+		stats            *connStats
+		maxResponseBytes int64
+		retryable        RetryableFunc
+		clock            clock
+		credentials      *google.Credentials
+		findCredentials  credentialsFinder
+		adcFile          string
+		signTimeout      time.Duration
+		signDialTimeout  time.Duration
+		checkAudience    bool
+		authType         GcpAuthType
+		jwtTtl           time.Duration
+		extraClaims      map[string]any
+		jwtNonce         bool
+		signRetry        SignRetryPolicy
 	}
 
 	gcpAuth struct {
+		opts []gcpAuthOption
 	}
+
+	// credentialsFinder discovers the application default credentials for
+	// the scopes, replaceable for tests
+	credentialsFinder func(ctx context.Context, scopes ...string) (*google.Credentials, error)
 )
 
-// getConfig provides a config object for newVaultToken
+// newGcpAuth makes the GCP VaultAuth, holding the options that customize
+// the config it provides
+func newGcpAuth(opts ...gcpAuthOption) *gcpAuth {
+	return &gcpAuth{
+		opts: opts,
+	}
+}
+
+// defaultAudience is the JWT audience Vault's GCP auth expects by default
+func defaultAudience(role string) string {
+	return "vault/" + role
+}
+
+// newDefaultAuth provides the cloud auth used when no static token is given
+func newDefaultAuth(vo *vaultOptions, stats *connStats) VaultAuth {
+	opts := []gcpAuthOption{
+		gcpWithForceHttp1(vo.forceHttp1),
+		gcpWithTransport(vo.transport),
+		gcpWithRequestedTtl(vo.requestedTtl),
+		gcpWithStats(stats),
+		gcpWithMaxResponseBytes(vo.maxResponseBytes),
+		gcpWithRetryable(vo.retryable),
+		gcpWithClock(vo.clock),
+		gcpWithLoginTemplate(vo.loginTemplate),
+	}
+	return newGcpAuth(append(opts, vo.gcpOpts...)...)
+}
+
+// authMethod identifies GCP auth
+func (auth *gcpAuth) authMethod() string {
+	return AuthMethodGcp
+}
+
+// getConfig provides a config object for newVaultToken. The defaults are
+// adjusted by the auth's options, applied in order.
 func (auth *gcpAuth) getConfig(l lane.Lane, vaultRole string) (cfg VaultAuthConfig, err error) {
 	// This specifies Vault's auth config
 	gcpcfg := gcpAuthConfig{
-		role:     vaultRole,
-		authPath: "auth/gcp",
+		role:            vaultRole,
+		authPath:        "auth/gcp",
+		scopes:          []string{kGcpAuthUrl},
+		transport:       httpVaultTransport{},
+		clock:           systemClock{},
+		followRedirects: true,
+		audience:        defaultAudience,
+		loginTemplate:   kLoginPathTemplate,
+		jwtTtl:          kJwtTokenTimeoutMins * time.Minute,
+		signRetry:       SignRetryPolicy{MaxRetries: kSignMaxRetries},
+		findCredentials: google.FindDefaultCredentials,
+	}
+	for _, opt := range auth.opts {
+		opt(&gcpcfg)
+	}
+
+	if err = validateAuthPath(gcpcfg.authPath); err != nil {
+		l.Errorf("vault-auth-gcp: invalid config: %v", err)
+		return
+	}
+	if err = validateLoginTemplate(gcpcfg.loginTemplate); err != nil {
+		l.Errorf("vault-auth-gcp: invalid config: %v", err)
+		return
+	}
+
+	if gcpcfg.jwtTtl <= 0 || gcpcfg.jwtTtl > kJwtMaxTimeoutMins*time.Minute {
+		err = fmt.Errorf("jwt ttl %v must be positive and at most %d minutes", gcpcfg.jwtTtl, kJwtMaxTimeoutMins)
+		l.Errorf("vault-auth-gcp: invalid config: %v", err)
+		return
+	}
+
+	if gcpcfg.debug != nil && gcpcfg.debug.exposeJwt {
+		l.Warnf("vault-auth-gcp: *** signed jwt exposure is enabled; this is for debugging only ***")
 	}
 
 	cfg = gcpcfg
 	return
 }
 
+// snapshot describes the GCP config for ExportConfig; only the names of
+// the extra claims are given, since their values may be sensitive
+func (gcpcfg gcpAuthConfig) snapshot(snap *ConfigSnapshot) {
+	snap.Role = gcpcfg.role
+	snap.AuthPath = gcpcfg.authPath
+
+	gs := &GCPConfigSnapshot{
+		AuthType:            "iam",
+		Audience:            gcpcfg.audience(gcpcfg.role),
+		Scopes:              gcpcfg.scopes,
+		ADCFile:             gcpcfg.adcFile,
+		JwtTTL:              gcpcfg.jwtTtl,
+		JwtNonce:            gcpcfg.jwtNonce,
+		CheckAudience:       gcpcfg.checkAudience,
+		SignMaxRetries:      gcpcfg.signRetry.retries(),
+		SignInitialInterval: gcpcfg.signRetry.InitialInterval,
+		SignMaxInterval:     gcpcfg.signRetry.MaxInterval,
+		SignMaxElapsedTime:  gcpcfg.signRetry.MaxElapsedTime,
+		SignTimeout:         gcpcfg.signTimeout,
+		SignDialTimeout:     gcpcfg.signDialTimeout,
+	}
+	if gcpcfg.authType == GcpAuthGce {
+		gs.AuthType = "gce"
+	}
+	for name := range gcpcfg.extraClaims {
+		gs.ExtraClaimNames = append(gs.ExtraClaimNames, name)
+	}
+	slices.Sort(gs.ExtraClaimNames)
+
+	snap.GCP = gs
+}
+
 func (auth *gcpAuth) newVaultToken(l lane.Lane, authCfg VaultAuthConfig, client *vaultapi.Client) (token VaultToken, err error) {
 	gcpcfg := authCfg.(gcpAuthConfig)
 	gat := newGcpAuthToken(&gcpcfg, client)
@@ -0,0 +1,1003 @@
+//go:build !nogcp
+
+package vaulttoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+	"golang.org/x/oauth2/google"
+)
+
+// newGcpClient makes a GCP auth client of the mock Vault server, whose
+// requests to Google are answered by rt
+func newGcpClient(t *testing.T, l lane.Lane, mv *mockVault, rt roundTripFunc, opts ...VaultOption) *VaultClientConnection {
+	stub := withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.testClient = &http.Client{Transport: rt}
+		cfg.credentials = testGcpCredentials()
+	})
+	vcc, err := NewVaultClient(l, mv.srv.URL, "", "", "", "my-role", append([]VaultOption{stub}, opts...)...)
+	if err != nil {
+		t.Fatalf("can't make gcp client: %v", err)
+	}
+	return vcc
+}
+
+// signJwtOk answers a signJwt request with a fixed signed JWT
+func signJwtOk(req *http.Request) (*http.Response, error) {
+	return jsonResponse(req, http.StatusOK, `{"keyId":"k1","signedJwt":"signed.jwt.value"}`), nil
+}
+
+func TestGcpLoginPathTemplate(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	vo := newVaultOptions([]VaultOption{WithGCPAuthPath("auth/gcp-prod"), WithLoginPathTemplate(LoginPathNamedTemplate)})
+
+	cfg, err := newDefaultAuth(vo, nil).getConfig(l, "my-role")
+	if err != nil {
+		t.Fatalf("can't make gcp config: %v", err)
+	}
+	gcpcfg := cfg.(gcpAuthConfig)
+	if path := expandLoginPath(gcpcfg.loginTemplate, gcpcfg.authPath, gcpcfg.role); path != "auth/gcp-prod/login/my-role" {
+		t.Errorf("unexpected login path %s", path)
+	}
+}
+
+func TestGcpLoginSignJwtApi(t *testing.T) {
+	cases := []struct {
+		api SignJwtApi
+		url string
+	}{
+		{SignJwtIamCredentials, "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/sa@proj.iam.gserviceaccount.com:signJwt"},
+		{SignJwtIamLegacy, "https://iam.googleapis.com/v1/projects/-/serviceAccounts/sa@proj.iam.gserviceaccount.com:signJwt"},
+	}
+	for _, c := range cases {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+
+		var signUrl string
+		vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+			signUrl = req.URL.String()
+			return signJwtOk(req)
+		}, WithSignJwtApi(c.api))
+
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Fatalf("api %d: login failed: %v", c.api, err)
+		}
+		if signUrl != c.url {
+			t.Errorf("api %d: unexpected signing url %s", c.api, signUrl)
+		}
+		body := mv.lastLoginBody()
+		if body["jwt"] != "signed.jwt.value" || body["role"] != "my-role" {
+			t.Errorf("api %d: unexpected login body %v", c.api, body)
+		}
+		if path := mv.lastLoginPath(); path != "auth/gcp/login" {
+			t.Errorf("api %d: unexpected login path %s", c.api, path)
+		}
+	}
+}
+
+func TestGcpLoginScopes(t *testing.T) {
+	cases := []struct {
+		opts   []VaultOption
+		scopes []string
+	}{
+		{nil, []string{kGcpAuthUrl}},
+		{[]VaultOption{WithGCPScopes(kGcpAuthUrl, "https://www.googleapis.com/auth/iam")}, []string{kGcpAuthUrl, "https://www.googleapis.com/auth/iam"}},
+	}
+	for _, c := range cases {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+
+		// discover the credentials rather than using the stub's
+		var scopes []string
+		discover := withGcpOption(func(cfg *gcpAuthConfig) {
+			cfg.credentials = nil
+			cfg.findCredentials = func(ctx context.Context, s ...string) (*google.Credentials, error) {
+				scopes = s
+				return testGcpCredentials(), nil
+			}
+		})
+		vcc := newGcpClient(t, l, mv, signJwtOk, append(c.opts, discover)...)
+
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if !slices.Equal(scopes, c.scopes) {
+			t.Errorf("expected scopes %v, got %v", c.scopes, scopes)
+		}
+	}
+}
+
+// signJwtClaims decodes the claims of a signJwt request
+func signJwtClaims(t *testing.T, req *http.Request) (claims map[string]any) {
+	var body struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		t.Errorf("can't parse signing request: %v", err)
+	}
+	if err := json.Unmarshal([]byte(body.Payload), &claims); err != nil {
+		t.Errorf("can't parse jwt claims: %v", err)
+	}
+	return
+}
+
+func TestGcpLoginJwtSubject(t *testing.T) {
+	cases := []struct {
+		subject JwtSubject
+		sub     string
+	}{
+		{JwtSubjectEmail, "sa@proj.iam.gserviceaccount.com"},
+		{JwtSubjectUniqueId, "109876543210987654321"},
+	}
+	for _, c := range cases {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+
+		var claims map[string]any
+		vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				// the service account lookup for its unique id
+				if req.URL.String() != "https://iam.googleapis.com/v1/projects/-/serviceAccounts/sa@proj.iam.gserviceaccount.com" {
+					t.Errorf("unexpected service account url %s", req.URL)
+				}
+				return jsonResponse(req, http.StatusOK, `{"email":"sa@proj.iam.gserviceaccount.com","uniqueId":"109876543210987654321"}`), nil
+			}
+			claims = signJwtClaims(t, req)
+			return signJwtOk(req)
+		}, WithJwtSubject(c.subject))
+
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Fatalf("subject %d: login failed: %v", c.subject, err)
+		}
+		if claims["sub"] != c.sub {
+			t.Errorf("subject %d: expected sub %s, got %v", c.subject, c.sub, claims["sub"])
+		}
+		if mv.lastLoginBody()["jwt"] != "signed.jwt.value" {
+			t.Errorf("subject %d: the signed jwt wasn't presented at login", c.subject)
+		}
+	}
+}
+
+func TestGcpLoginSignRetryNotify(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	type retry struct {
+		attempt int
+		delay   time.Duration
+	}
+	var retries []retry
+	notify := func(attempt int, err error, delay time.Duration) {
+		if err == nil {
+			t.Error("expected the failure with the notification")
+		}
+		retries = append(retries, retry{attempt, delay})
+	}
+
+	failures := 3
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		if failures > 0 {
+			failures--
+			return jsonResponse(req, http.StatusServiceUnavailable,
+				`{"error":{"code":503,"status":"UNAVAILABLE","message":"try again"}}`), nil
+		}
+		return signJwtOk(req)
+	}, WithSignRetryNotify(notify), WithSignRetryPolicy(SignRetryPolicy{InitialInterval: 10 * time.Millisecond}))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if len(retries) != 3 {
+		t.Fatalf("expected 3 notifications, got %d", len(retries))
+	}
+
+	// the exponential backoff grows the interval by 1.5x, randomized by
+	// up to half either way
+	interval := 10 * time.Millisecond
+	for i, r := range retries {
+		if r.attempt != i+1 {
+			t.Errorf("expected attempt %d, got %d", i+1, r.attempt)
+		}
+		if r.delay < interval/2 || r.delay > interval*3/2 {
+			t.Errorf("attempt %d: delay %s is outside %s ± 50%%", r.attempt, r.delay, interval)
+		}
+		interval = interval * 3 / 2
+	}
+}
+
+func TestGcpLoginIamErrorFields(t *testing.T) {
+	cases := []struct {
+		httpStatus int
+		body       string
+		want       GCPIAMError
+	}{
+		{
+			http.StatusForbidden,
+			`{"error":{"code":403,"status":"PERMISSION_DENIED","message":"iam.serviceAccounts.signJwt denied"}}`,
+			GCPIAMError{Code: 403, Status: "PERMISSION_DENIED", Message: "iam.serviceAccounts.signJwt denied"},
+		},
+		{
+			http.StatusTooManyRequests,
+			`{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","message":"quota exceeded"}}`,
+			GCPIAMError{Code: 429, Status: "RESOURCE_EXHAUSTED", Message: "quota exceeded"},
+		},
+		{
+			// no code in the error object; the http status stands in
+			http.StatusBadRequest,
+			`{"error":{"status":"INVALID_ARGUMENT","message":"bad payload"}}`,
+			GCPIAMError{Code: 400, Status: "INVALID_ARGUMENT", Message: "bad payload"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.want.Status, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+				return jsonResponse(req, c.httpStatus, c.body), nil
+			}, WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}))
+
+			_, err := vcc.GetApiInterface(l)
+			var iamErr *GCPIAMError
+			if !errors.As(err, &iamErr) {
+				t.Fatalf("expected a GCPIAMError, got %v", err)
+			}
+			if iamErr.Code != c.want.Code || iamErr.Status != c.want.Status || iamErr.Message != c.want.Message {
+				t.Errorf("expected %+v, got %+v", c.want, *iamErr)
+			}
+			if n := mv.logins.Load(); n != 0 {
+				t.Errorf("vault was asked to log in %d times without a signed jwt", n)
+			}
+		})
+	}
+}
+
+func TestGcpAuthMethod(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newGcpClient(t, l, mv, signJwtOk)
+
+	if method := vcc.AuthMethod(); method != AuthMethodGcp {
+		t.Errorf("expected auth method %q, got %q", AuthMethodGcp, method)
+	}
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if path := mv.lastLoginPath(); path != "auth/gcp/login" {
+		t.Errorf("unexpected login path %s", path)
+	}
+}
+
+func TestGcpLoginSignerRedirects(t *testing.T) {
+	cases := []struct {
+		name     string
+		target   string
+		opts     []VaultOption
+		followed bool
+	}{
+		{"followed by default", "https://iam-standby.example.com/signJwt", nil, true},
+		{"not followed when disabled", "https://iam-standby.example.com/signJwt", []VaultOption{WithSignerRedirects(false)}, false},
+		{"https to http refused", "http://iam-standby.example.com/signJwt", nil, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+
+			var redirected []*http.Request
+			vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+				if req.URL.Host == "iam-standby.example.com" {
+					redirected = append(redirected, req)
+					return signJwtOk(req)
+				}
+				resp := jsonResponse(req, http.StatusTemporaryRedirect, `{}`)
+				resp.Header.Set("Location", c.target)
+				return resp, nil
+			}, append([]VaultOption{WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries})}, c.opts...)...)
+
+			_, err := vcc.GetApiInterface(l)
+			if !c.followed {
+				if err == nil {
+					t.Error("expected the login to fail at the redirect")
+				}
+				if len(redirected) != 0 {
+					t.Errorf("the redirect was followed to %s", redirected[0].URL)
+				}
+				if n := mv.logins.Load(); n != 0 {
+					t.Errorf("expected no vault login, got %d", n)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			if len(redirected) != 1 {
+				t.Fatalf("expected the redirect to be followed once, got %d", len(redirected))
+			}
+			// the followed hop is still authorized by the oauth2 transport
+			if auth := redirected[0].Header.Get("Authorization"); auth != "Bearer gcp-access-token" {
+				t.Errorf("the redirected request wasn't authorized: %q", auth)
+			}
+			if claims := signJwtClaims(t, redirected[0]); claims["aud"] != "vault/my-role" {
+				t.Errorf("the redirected request lost its payload: %v", claims)
+			}
+		})
+	}
+}
+
+func TestGcpLoginExternalAccount(t *testing.T) {
+	const federated = `{
+		"type": "external_account",
+		"audience": "//iam.googleapis.com/projects/123/locations/global/workloadIdentityPools/aws/providers/aws",
+		"subject_token_type": "urn:ietf:params:aws:token-type:aws4_request",
+		"token_url": "https://sts.googleapis.com/v1/token",
+		"service_account_impersonation_url": "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/fed-sa%40proj.iam.gserviceaccount.com:generateAccessToken",
+		"credential_source": {"environment_id": "aws1"}
+	}`
+	federatedCreds := withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.credentials = &google.Credentials{
+			JSON:        []byte(federated),
+			TokenSource: testGcpCredentials().TokenSource,
+		}
+	})
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	var signUrl string
+	var claims map[string]any
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		signUrl = req.URL.String()
+		claims = signJwtClaims(t, req)
+		return signJwtOk(req)
+	}, federatedCreds)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// the impersonated service account signs, and is the jwt's subject
+	if signUrl != "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/fed-sa@proj.iam.gserviceaccount.com:signJwt" {
+		t.Errorf("unexpected signJwt url %s", signUrl)
+	}
+	if claims["sub"] != "fed-sa@proj.iam.gserviceaccount.com" {
+		t.Errorf("unexpected sub claim %v", claims["sub"])
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 vault login, got %d", n)
+	}
+}
+
+func TestGcpLoginExternalAccountWithoutImpersonation(t *testing.T) {
+	unimpersonated := withGcpOption(func(cfg *gcpAuthConfig) {
+		cfg.credentials = &google.Credentials{
+			JSON:        []byte(`{"type":"external_account","audience":"//iam.googleapis.com/projects/123","token_url":"https://sts.googleapis.com/v1/token"}`),
+			TokenSource: testGcpCredentials().TokenSource,
+		}
+	})
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		t.Errorf("a federated identity without a service account was used to sign at %s", req.URL)
+		return signJwtOk(req)
+	}, unimpersonated, WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}))
+
+	if _, err := vcc.GetApiInterface(l); err == nil {
+		t.Error("expected the login to fail")
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no vault login, got %d", n)
+	}
+}
+
+func TestGcpLoginJwtExpRounding(t *testing.T) {
+	// ceilSecs rounds t up to a whole second
+	ceilSecs := func(t time.Time) int64 {
+		if t.Nanosecond() > 0 {
+			return t.Unix() + 1
+		}
+		return t.Unix()
+	}
+
+	cases := []struct {
+		name  string
+		opts  []VaultOption
+		bound func(t time.Time) int64
+	}{
+		{"default", nil, func(t time.Time) int64 { return t.Add(time.Minute).Unix() }},
+		{"padded", []VaultOption{WithJwtExpRounding(false, 5*time.Second)}, func(t time.Time) int64 { return t.Add(time.Minute + 5*time.Second).Unix() }},
+		{"ceil and padded", []VaultOption{WithJwtExpRounding(true, 5*time.Second)}, func(t time.Time) int64 { return ceilSecs(t.Add(time.Minute + 5*time.Second)) }},
+		{"ttl", []VaultOption{WithJwtTTL(10 * time.Minute)}, func(t time.Time) int64 { return t.Add(10 * time.Minute).Unix() }},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+
+			var claims map[string]any
+			vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+				claims = signJwtClaims(t, req)
+				return signJwtOk(req)
+			}, c.opts...)
+
+			before := time.Now()
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			after := time.Now()
+
+			exp, _ := claims["exp"].(float64)
+			if int64(exp) < c.bound(before) || int64(exp) > c.bound(after) {
+				t.Errorf("exp %d is outside [%d, %d]", int64(exp), c.bound(before), c.bound(after))
+			}
+		})
+	}
+}
+
+func TestGcpLoginAudienceFunc(t *testing.T) {
+	cases := []struct {
+		name string
+		opts []VaultOption
+		aud  string
+	}{
+		{"default", nil, "vault/my-role"},
+		{"custom", []VaultOption{WithAudienceFunc(func(role string) string { return "https://vault.example.com/gcp/" + role })}, "https://vault.example.com/gcp/my-role"},
+		{"nil keeps default", []VaultOption{WithAudienceFunc(nil)}, "vault/my-role"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+
+			var claims map[string]any
+			vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+				claims = signJwtClaims(t, req)
+				return signJwtOk(req)
+			}, c.opts...)
+
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			if claims["aud"] != c.aud {
+				t.Errorf("expected aud %q, got %v", c.aud, claims["aud"])
+			}
+		})
+	}
+}
+
+func TestGcpRejectsBadAuthPath(t *testing.T) {
+	for _, authPath := range []string{"", "gcp"} {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+
+		_, err := NewVaultClient(l, mv.srv.URL, "", "", "", "my-role", WithGCPAuthPath(authPath))
+		if err == nil || !strings.Contains(err.Error(), "auth path") {
+			t.Errorf("auth path %q: expected a clear auth path error, got %v", authPath, err)
+		}
+	}
+}
+
+func TestGcpRejectsBadJwtTTL(t *testing.T) {
+	for _, ttl := range []time.Duration{0, 16 * time.Minute} {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+
+		_, err := NewVaultClient(l, mv.srv.URL, "", "", "", "my-role", WithJwtTTL(ttl))
+		if err == nil || !strings.Contains(err.Error(), "jwt ttl") {
+			t.Errorf("jwt ttl %v: expected a clear jwt ttl error, got %v", ttl, err)
+		}
+	}
+}
+
+func TestGcpLoginRequestedTTL(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newGcpClient(t, l, mv, signJwtOk, WithRequestedTTL(15*time.Minute))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	body := mv.lastLoginBody()
+	if body["ttl"] != "900s" {
+		t.Errorf("expected ttl 900s in the login body, got %v", body["ttl"])
+	}
+	if body["jwt"] != "signed.jwt.value" {
+		t.Errorf("unexpected login jwt %v", body["jwt"])
+	}
+}
+
+func TestGcpLoginSignStats(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	failures := 2
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		if failures > 0 {
+			failures--
+			return jsonResponse(req, http.StatusServiceUnavailable,
+				`{"error":{"code":503,"status":"UNAVAILABLE","message":"try again"}}`), nil
+		}
+		return signJwtOk(req)
+	}, WithSignRetryPolicy(SignRetryPolicy{InitialInterval: time.Millisecond}))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	stats := vcc.Stats()
+	if stats.SignAttempts != 3 || stats.SignFailures != 2 {
+		t.Errorf("expected 3 sign attempts with 2 failures, got %+v", stats)
+	}
+	if stats.Logins != 1 {
+		t.Errorf("expected 1 login, got %d", stats.Logins)
+	}
+	var iamErr *GCPIAMError
+	if !errors.As(stats.LastError, &iamErr) || iamErr.Status != "UNAVAILABLE" {
+		t.Errorf("expected the sign failure as the last error, got %v", stats.LastError)
+	}
+}
+
+func TestGcpLoginMaxResponseBytes(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	padding := strings.Repeat("x", 4096)
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		return jsonResponse(req, http.StatusOK, `{"keyId":"k1","signedJwt":"signed.jwt.value","padding":"`+padding+`"}`), nil
+	}, WithMaxResponseBytes(1024), WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}))
+
+	if _, err := vcc.GetApiInterface(l); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge, got %v", err)
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no vault login, got %d", n)
+	}
+}
+
+// writeAdcFile writes a service account key file for email whose tokens are
+// issued by a local token endpoint, providing the file path
+func writeAdcFile(t *testing.T, email string) string {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, `{"access_token":"adc-access-token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	t.Cleanup(tokenSrv.Close)
+
+	adc, _ := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"client_email":   email,
+		"private_key_id": "key-1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})),
+		"token_uri":      tokenSrv.URL,
+	})
+	path := filepath.Join(t.TempDir(), "application_default_credentials.json")
+	if err = os.WriteFile(path, adc, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGcpLoginADCFile(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	adcFile := writeAdcFile(t, "dev@proj.iam.gserviceaccount.com")
+
+	var signUrl, auth string
+	var claims map[string]any
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		signUrl = req.URL.String()
+		auth = req.Header.Get("Authorization")
+		claims = signJwtClaims(t, req)
+		return signJwtOk(req)
+	}, WithADCFile(adcFile), withGcpOption(func(cfg *gcpAuthConfig) {
+		// no injected credentials, so the file is used
+		cfg.credentials = nil
+		cfg.findCredentials = func(ctx context.Context, scopes ...string) (*google.Credentials, error) {
+			t.Error("credentials were discovered rather than read from the adc file")
+			return nil, errors.New("no discovery in tests")
+		}
+	}))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if signUrl != "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/dev@proj.iam.gserviceaccount.com:signJwt" {
+		t.Errorf("unexpected signJwt url %s", signUrl)
+	}
+	if claims["sub"] != "dev@proj.iam.gserviceaccount.com" {
+		t.Errorf("unexpected sub claim %v", claims["sub"])
+	}
+	if auth != "Bearer adc-access-token" {
+		t.Errorf("the signJwt call wasn't authorized by the file's credentials: %q", auth)
+	}
+}
+
+func TestGcpLoginADCFileMissing(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	vcc := newGcpClient(t, l, mv, signJwtOk, WithADCFile(filepath.Join(t.TempDir(), "absent.json")),
+		WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}),
+		withGcpOption(func(cfg *gcpAuthConfig) { cfg.credentials = nil }))
+
+	if _, err := vcc.GetApiInterface(l); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected the missing file to fail the login, got %v", err)
+	}
+}
+
+func TestGcpLoginSignTimeout(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	// a signJwt call that never answers
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	}, WithSignTimeout(100*time.Millisecond), WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}))
+
+	start := time.Now()
+	_, err := vcc.GetApiInterface(l)
+	if !errors.Is(err, ErrJWTSigningFailed) {
+		t.Errorf("expected the signing to fail, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the signing took %v, past its timeout", elapsed)
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no vault login, got %d", n)
+	}
+}
+
+func TestGcpLoginAudienceCheck(t *testing.T) {
+	// a refused audience would otherwise be retried
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	fc := newFakeClock()
+
+	var signs atomic.Int32
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		signs.Add(1)
+		return signJwtOk(req)
+	}, WithAudienceCheck(), withClock(fc), WithRetryable(func(error) bool { return false }))
+
+	// without a token yet the role can't be read, so the check is skipped
+	mv.addGcpRole("my-role", "vault/other-role")
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// once the role is readable, a mismatch fails before signing
+	fc.advance(fc.Now().Add(2 * time.Hour))
+	_, err := vcc.GetApiInterface(l)
+	if err == nil || !strings.Contains(err.Error(), "bound_audiences") {
+		t.Fatalf("expected an audience mismatch, got %v", err)
+	}
+	if n := signs.Load(); n != 1 {
+		t.Errorf("expected only the first login to sign, got %d", n)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 vault login, got %d", n)
+	}
+
+	// and a matching audience proceeds
+	mv.addGcpRole("my-role", "vault/other-role", "vault/my-role")
+	if _, err = vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login with a bound audience failed: %v", err)
+	}
+	if n := mv.logins.Load(); n != 2 {
+		t.Errorf("expected 2 vault logins, got %d", n)
+	}
+}
+
+func TestGcpLoginCredentialShapes(t *testing.T) {
+	cases := []struct {
+		name, json, signer string
+	}{
+		{
+			name:   "service_account",
+			json:   `{"type":"service_account","project_id":"proj","private_key_id":"k1","client_email":"key-sa@proj.iam.gserviceaccount.com","client_id":"1"}`,
+			signer: "key-sa@proj.iam.gserviceaccount.com",
+		},
+		{
+			name:   "impersonated_service_account",
+			json:   `{"type":"impersonated_service_account","service_account_impersonation_url":"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/imp-sa@proj.iam.gserviceaccount.com:generateAccessToken","delegates":[],"source_credentials":{"type":"authorized_user","client_id":"c","client_secret":"s","refresh_token":"r"}}`,
+			signer: "imp-sa@proj.iam.gserviceaccount.com",
+		},
+		{
+			name:   "external_account",
+			json:   `{"type":"external_account","audience":"//iam.googleapis.com/projects/123","subject_token_type":"urn:ietf:params:oauth:token-type:jwt","token_url":"https://sts.googleapis.com/v1/token","service_account_impersonation_url":"https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/fed-sa@proj.iam.gserviceaccount.com:generateAccessToken","credential_source":{"file":"/var/run/token"}}`,
+			signer: "fed-sa@proj.iam.gserviceaccount.com",
+		},
+		{
+			// a user can't sign, so there's no identity to use
+			name: "authorized_user",
+			json: `{"type":"authorized_user","client_id":"c","client_secret":"s","refresh_token":"r"}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			creds := withGcpOption(func(cfg *gcpAuthConfig) {
+				cfg.credentials = &google.Credentials{
+					JSON:        []byte(c.json),
+					TokenSource: testGcpCredentials().TokenSource,
+				}
+			})
+
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+
+			var signUrl string
+			vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+				signUrl = req.URL.String()
+				return signJwtOk(req)
+			}, creds, WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}))
+
+			_, err := vcc.GetApiInterface(l)
+			if c.signer == "" {
+				if err == nil {
+					t.Error("expected the login to fail")
+				}
+				if signUrl != "" {
+					t.Errorf("signed without an identity at %s", signUrl)
+				}
+				if n := mv.logins.Load(); n != 0 {
+					t.Errorf("expected no vault login, got %d", n)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			if want := "https://iamcredentials.googleapis.com/v1/projects/-/serviceAccounts/" + c.signer + ":signJwt"; signUrl != want {
+				t.Errorf("unexpected signJwt url %s", signUrl)
+			}
+		})
+	}
+}
+
+func TestGcpLoginAuthPath(t *testing.T) {
+	cases := []struct {
+		opts []VaultOption
+		path string
+	}{
+		{nil, "auth/gcp/login"},
+		{[]VaultOption{WithGCPAuthPath("auth/gcp-prod")}, "auth/gcp-prod/login"},
+	}
+	for _, c := range cases {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+
+		vcc := newGcpClient(t, l, mv, signJwtOk, c.opts...)
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Fatalf("login failed: %v", err)
+		}
+		if path := mv.lastLoginPath(); path != c.path {
+			t.Errorf("unexpected login path %s, expected %s", path, c.path)
+		}
+	}
+}
+
+func TestGcpLoginJwtClaims(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+
+	var claims map[string]any
+	vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+		claims = signJwtClaims(t, req)
+		return signJwtOk(req)
+	}, WithJwtClaims(map[string]any{
+		"team": "payments",
+		"aud":  "vault/other-role",
+		"sub":  "other@proj.iam.gserviceaccount.com",
+		"exp":  1,
+	}))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// the extra claim is added, and the required ones are kept
+	if claims["team"] != "payments" {
+		t.Errorf("the extra claim is missing: %v", claims)
+	}
+	if claims["aud"] != "vault/my-role" || claims["sub"] != "sa@proj.iam.gserviceaccount.com" {
+		t.Errorf("a required claim was overridden: %v", claims)
+	}
+	if exp, _ := claims["exp"].(float64); int64(exp) < time.Now().Unix() {
+		t.Errorf("the exp claim was overridden: %v", claims["exp"])
+	}
+}
+
+func TestGcpSignTimeouts(t *testing.T) {
+	// a server that accepts connections but never answers the handshake
+	silent, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// and one that completes the handshake but never responds
+	release := make(chan struct{})
+	stalled := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer stalled.Close()
+	defer close(release)
+
+	cases := []struct {
+		name   string
+		addr   string
+		opts   []VaultOption
+		reason string
+	}{
+		{"connect", silent.Addr().String(), []VaultOption{WithSignDialTimeout(100 * time.Millisecond)}, "TLS handshake timeout"},
+		{"response", stalled.Listener.Addr().String(), []VaultOption{WithSignDialTimeout(5 * time.Second), WithSignTimeout(100 * time.Millisecond)}, "Client.Timeout exceeded"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// the signer's own transport, with its connections sent to the
+			// local server in place of Google
+			redirected := withGcpOption(func(cfg *gcpAuthConfig) {
+				tr := cfg.signTransport()
+				dial := tr.DialContext
+				if dial == nil {
+					dial = (&net.Dialer{}).DialContext
+				}
+				tr.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+					return dial(ctx, network, c.addr)
+				}
+				tr.TLSClientConfig = &tls.Config{RootCAs: x509.NewCertPool(), ServerName: "example.com"}
+				tr.TLSClientConfig.RootCAs.AddCert(stalled.Certificate())
+				cfg.testClient = &http.Client{Transport: tr}
+			})
+
+			l := lane.NewTestingLane(context.Background())
+			mv := newMockVault(t)
+			opts := append(c.opts, redirected, WithSignRetryPolicy(SignRetryPolicy{MaxRetries: SignNoRetries}))
+			vcc := newGcpClient(t, l, mv, signJwtOk, opts...)
+
+			start := time.Now()
+			_, err := vcc.GetApiInterface(l)
+			if !errors.Is(err, ErrJWTSigningFailed) || !strings.Contains(err.Error(), c.reason) {
+				t.Errorf("expected a signing failure with %q, got %v", c.reason, err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("the signing took %v, past its timeout", elapsed)
+			}
+			if n := mv.logins.Load(); n != 0 {
+				t.Errorf("expected no vault login, got %d", n)
+			}
+		})
+	}
+}
+
+func TestGcpLoginJwtNonce(t *testing.T) {
+	for _, nonce := range []bool{false, true} {
+		l := lane.NewTestingLane(context.Background())
+		mv := newMockVault(t)
+		fc := newFakeClock()
+
+		var jtis []any
+		vcc := newGcpClient(t, l, mv, func(req *http.Request) (*http.Response, error) {
+			claims := signJwtClaims(t, req)
+			if jti, has := claims["jti"]; has {
+				jtis = append(jtis, jti)
+			}
+			return signJwtOk(req)
+		}, WithJwtNonce(nonce), withClock(fc))
+
+		// sign twice, logging in again once the first token expires
+		for range 2 {
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			fc.advance(fc.Now().Add(2 * time.Hour))
+		}
+
+		if !nonce {
+			if len(jtis) != 0 {
+				t.Errorf("expected no jti claim, got %v", jtis)
+			}
+			continue
+		}
+		if len(jtis) != 2 {
+			t.Fatalf("expected a jti claim in each jwt, got %v", jtis)
+		}
+		if s, _ := jtis[0].(string); s == "" || jtis[0] == jtis[1] {
+			t.Errorf("expected a unique jti per jwt, got %v", jtis)
+		}
+	}
+}
+
+func TestGcpExportConfig(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newGcpClient(t, l, mv, signJwtOk,
+		WithGCPAuthPath("auth/gcp-prod"),
+		WithJwtTTL(5*time.Minute),
+		WithJwtNonce(true),
+		WithJwtClaims(map[string]any{"team": "payments-secret-value", "env": "prod"}),
+		WithAudienceCheck(),
+		WithSignTimeout(3*time.Second),
+		WithSignDialTimeout(time.Second),
+		WithSignRetryPolicy(SignRetryPolicy{MaxRetries: 2}),
+	)
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	snap, err := vcc.ExportConfig()
+	if err != nil {
+		t.Fatalf("can't export config: %v", err)
+	}
+	if snap.AuthMethod != AuthMethodGcp || snap.Role != "my-role" || snap.AuthPath != "auth/gcp-prod" {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+	gs := snap.GCP
+	if gs == nil {
+		t.Fatal("the snapshot has no gcp settings")
+	}
+	if gs.AuthType != "iam" || gs.Audience != "vault/my-role" || gs.JwtTTL != 5*time.Minute || !gs.JwtNonce || !gs.CheckAudience {
+		t.Errorf("unexpected gcp snapshot %+v", gs)
+	}
+	if gs.SignMaxRetries != 2 || gs.SignTimeout != 3*time.Second || gs.SignDialTimeout != time.Second {
+		t.Errorf("unexpected gcp sign settings %+v", gs)
+	}
+	if !slices.Equal(gs.ExtraClaimNames, []string{"env", "team"}) {
+		t.Errorf("unexpected extra claim names %v", gs.ExtraClaimNames)
+	}
+
+	// claim values and tokens are left out
+	js, _ := json.Marshal(snap)
+	vc, _ := vcc.GetApiInterface(l)
+	for _, secret := range []string{"payments-secret-value", "gcp-access-token", "signed.jwt.value", vc.Token()} {
+		if strings.Contains(string(js), secret) {
+			t.Errorf("the snapshot holds %s: %s", secret, js)
+		}
+	}
+}
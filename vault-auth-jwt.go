@@ -0,0 +1,145 @@
+package vaulttoken
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// JwtSource provides the JWT presented at each login of the generic
+	// jwt auth method. It is called for every login, so a source can hand
+	// out freshly minted short-lived tokens.
+	JwtSource func() (jwt string, err error)
+
+	jwtAuthConfig struct {
+		role          string
+		authPath      string
+		source        JwtSource
+		loginTemplate string
+		transport     VaultTransport
+		requestedTtl  time.Duration
+		retryable     RetryableFunc
+		clock         clock
+	}
+
+	jwtAuth struct {
+		source JwtSource
+		vo     *vaultOptions
+	}
+)
+
+// newJwtAuth makes the generic JWT/OIDC VaultAuth
+func newJwtAuth(vo *vaultOptions, source JwtSource) *jwtAuth {
+	return &jwtAuth{
+		source: source,
+		vo:     vo,
+	}
+}
+
+// JwtFromString provides the same JWT for every login.
+func JwtFromString(jwt string) JwtSource {
+	return func() (string, error) {
+		return jwt, nil
+	}
+}
+
+// JwtFromFile reads the JWT from path at every login, so a token file
+// rotated by another process is picked up.
+func JwtFromFile(path string) JwtSource {
+	return func() (jwt string, err error) {
+		var content []byte
+		if content, err = os.ReadFile(path); err != nil {
+			err = fmt.Errorf("can't read jwt file %s: %w", path, err)
+			return
+		}
+
+		jwt = strings.TrimSpace(string(content))
+		if jwt == "" {
+			err = fmt.Errorf("jwt file %s is empty", path)
+		}
+		return
+	}
+}
+
+// WithJwtAuthPath sets where the generic jwt auth method is mounted in
+// Vault. The default is "auth/jwt".
+func WithJwtAuthPath(path string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.jwtPath = path
+	}
+}
+
+// authMethod identifies generic JWT auth
+func (auth *jwtAuth) authMethod() string {
+	return AuthMethodJwt
+}
+
+// getConfig provides a config object for newVaultToken
+func (auth *jwtAuth) getConfig(l lane.Lane, vaultRole string) (cfg VaultAuthConfig, err error) {
+	jcfg := jwtAuthConfig{
+		role:          vaultRole,
+		authPath:      "auth/jwt",
+		source:        auth.source,
+		loginTemplate: auth.vo.loginTemplate,
+		transport:     auth.vo.transport,
+		requestedTtl:  auth.vo.requestedTtl,
+		retryable:     auth.vo.retryable,
+		clock:         auth.vo.clock,
+	}
+	if auth.vo.jwtPath != "" {
+		jcfg.authPath = auth.vo.jwtPath
+	}
+
+	if jcfg.source == nil {
+		err = errors.New("no jwt source provided")
+		l.Errorf("vault-auth-jwt: invalid config: %v", err)
+		return
+	}
+
+	if err = validateAuthPath(jcfg.authPath); err != nil {
+		l.Errorf("vault-auth-jwt: invalid config: %v", err)
+		return
+	}
+	if err = validateLoginTemplate(jcfg.loginTemplate); err != nil {
+		l.Errorf("vault-auth-jwt: invalid config: %v", err)
+		return
+	}
+
+	cfg = jcfg
+	return
+}
+
+// snapshot describes the JWT config for ExportConfig
+func (jcfg jwtAuthConfig) snapshot(snap *ConfigSnapshot) {
+	snap.Role = jcfg.role
+	snap.AuthPath = jcfg.authPath
+}
+
+func (auth *jwtAuth) newVaultToken(l lane.Lane, authCfg VaultAuthConfig, client *vaultapi.Client) (token VaultToken, err error) {
+	jcfg := authCfg.(jwtAuthConfig)
+	loginPath := expandLoginPath(jcfg.loginTemplate, jcfg.authPath, jcfg.role)
+	lt := newLoginToken(client, jcfg.transport, loginPath, func(l lane.Lane) (jsonData map[string]any, err error) {
+		var jwt string
+		if jwt, err = jcfg.source(); err != nil {
+			l.Errorf("vault-auth-jwt: can't get jwt for login: %v", err)
+			return
+		}
+
+		jsonData = map[string]any{
+			"role": jcfg.role,
+			"jwt":  jwt,
+		}
+		return
+	})
+	lt.requestedTtl = jcfg.requestedTtl
+	lt.retryable = jcfg.retryable
+	lt.clock = jcfg.clock
+	token = lt
+	return
+}
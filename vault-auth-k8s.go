@@ -0,0 +1,137 @@
+package vaulttoken
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+const kK8sTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+type (
+	k8sAuthConfig struct {
+		role          string
+		authPath      string
+		tokenFile     string
+		loginTemplate string
+		transport     VaultTransport
+		requestedTtl  time.Duration
+		retryable     RetryableFunc
+		clock         clock
+	}
+
+	k8sAuth struct {
+		vo *vaultOptions
+	}
+)
+
+// newK8sAuth makes the Kubernetes VaultAuth
+func newK8sAuth(vo *vaultOptions) *k8sAuth {
+	return &k8sAuth{
+		vo: vo,
+	}
+}
+
+// WithKubernetesAuthPath sets where the Kubernetes auth method is mounted in
+// Vault. The default is "auth/kubernetes".
+func WithKubernetesAuthPath(path string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.k8sPath = path
+	}
+}
+
+// WithKubernetesTokenFile sets the file holding the pod's service account
+// JWT. The default is the standard mount location,
+// /var/run/secrets/kubernetes.io/serviceaccount/token.
+func WithKubernetesTokenFile(path string) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.k8sTokenFile = path
+	}
+}
+
+// authMethod identifies Kubernetes auth
+func (auth *k8sAuth) authMethod() string {
+	return AuthMethodKubernetes
+}
+
+// getConfig provides a config object for newVaultToken
+func (auth *k8sAuth) getConfig(l lane.Lane, vaultRole string) (cfg VaultAuthConfig, err error) {
+	kcfg := k8sAuthConfig{
+		role:          vaultRole,
+		authPath:      "auth/kubernetes",
+		tokenFile:     kK8sTokenFile,
+		loginTemplate: auth.vo.loginTemplate,
+		transport:     auth.vo.transport,
+		requestedTtl:  auth.vo.requestedTtl,
+		retryable:     auth.vo.retryable,
+		clock:         auth.vo.clock,
+	}
+	if auth.vo.k8sPath != "" {
+		kcfg.authPath = auth.vo.k8sPath
+	}
+	if auth.vo.k8sTokenFile != "" {
+		kcfg.tokenFile = auth.vo.k8sTokenFile
+	}
+
+	if err = validateAuthPath(kcfg.authPath); err != nil {
+		l.Errorf("vault-auth-k8s: invalid config: %v", err)
+		return
+	}
+	if err = validateLoginTemplate(kcfg.loginTemplate); err != nil {
+		l.Errorf("vault-auth-k8s: invalid config: %v", err)
+		return
+	}
+
+	cfg = kcfg
+	return
+}
+
+// snapshot describes the Kubernetes config for ExportConfig
+func (kcfg k8sAuthConfig) snapshot(snap *ConfigSnapshot) {
+	snap.Role = kcfg.role
+	snap.AuthPath = kcfg.authPath
+	snap.KubernetesTokenFile = kcfg.tokenFile
+}
+
+func (auth *k8sAuth) newVaultToken(l lane.Lane, authCfg VaultAuthConfig, client *vaultapi.Client) (token VaultToken, err error) {
+	kcfg := authCfg.(k8sAuthConfig)
+	loginPath := expandLoginPath(kcfg.loginTemplate, kcfg.authPath, kcfg.role)
+	lt := newLoginToken(client, kcfg.transport, loginPath, func(l lane.Lane) (jsonData map[string]any, err error) {
+		var jwt string
+		if jwt, err = readK8sToken(kcfg.tokenFile); err != nil {
+			l.Errorf("vault-auth-k8s: %v", err)
+			return
+		}
+
+		jsonData = map[string]any{
+			"role": kcfg.role,
+			"jwt":  jwt,
+		}
+		return
+	})
+	lt.requestedTtl = kcfg.requestedTtl
+	lt.retryable = kcfg.retryable
+	lt.clock = kcfg.clock
+	token = lt
+	return
+}
+
+// readK8sToken loads the service account JWT; it is read for each login
+// because the kubelet rotates it
+func readK8sToken(tokenFile string) (jwt string, err error) {
+	var content []byte
+	if content, err = os.ReadFile(tokenFile); err != nil {
+		err = fmt.Errorf("can't read kubernetes service account token %s: %w", tokenFile, err)
+		return
+	}
+
+	jwt = strings.TrimSpace(string(content))
+	if jwt == "" {
+		err = fmt.Errorf("kubernetes service account token %s is empty", tokenFile)
+	}
+	return
+}
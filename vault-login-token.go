@@ -0,0 +1,247 @@
+package vaulttoken
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v3"
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// kLoginMaxRetries is how many times a login request failing with a
+// retryable error is repeated
+const kLoginMaxRetries = 3
+
+type (
+	// loginCredentials provides the body of a login request
+	loginCredentials func(l lane.Lane) (body map[string]any, err error)
+
+	// loginToken implements VaultToken for the auth methods that obtain a
+	// token with a login request, tracking the token's TTL; mu guards the
+	// token and expiration
+	loginToken struct {
+		mu           sync.Mutex
+		token        *vaultapi.Secret
+		expiration   time.Time
+		client       *vaultapi.Client
+		transport    VaultTransport
+		loginPath    string
+		credentials  loginCredentials
+		requestedTtl time.Duration
+		retryable    RetryableFunc
+		clock        clock
+	}
+)
+
+// newLoginToken returns a VaultToken that logs in at loginPath with the body
+// made by credentials
+func newLoginToken(client *vaultapi.Client, transport VaultTransport, loginPath string, credentials loginCredentials) *loginToken {
+	return &loginToken{
+		client:      client,
+		transport:   transport,
+		loginPath:   loginPath,
+		credentials: credentials,
+		retryable:   DefaultRetryable,
+		clock:       systemClock{},
+	}
+}
+
+// getToken performs a fresh login to Vault if there isn't a token yet
+func (lt *loginToken) getToken(l lane.Lane) (token *vaultapi.Secret, err error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if lt.token == nil {
+		var jsonData map[string]any
+		if jsonData, err = lt.credentials(l); err != nil {
+			return
+		}
+		if lt.requestedTtl > 0 {
+			jsonData["ttl"] = fmt.Sprintf("%ds", int64(lt.requestedTtl/time.Second))
+		}
+
+		// capture time before the login request
+		now := lt.clock.Now()
+
+		// a 5xx or network blip is retried, but a 4xx won't succeed by repeating it
+		var resp *vaultapi.Secret
+		rb := newRetryAfterBackOff(backoff.NewExponentialBackOff())
+		err = backoff.RetryNotify(func() (err error) {
+			resp, err = lt.transport.Login(l, lt.client, lt.loginPath, jsonData)
+			return permanentUnless(lt.retryable, rb.record(err))
+		}, backoff.WithContext(backoff.WithMaxRetries(rb, kLoginMaxRetries), l), func(err error, delay time.Duration) {
+			l.Warnf("vault login request failed, retrying in %s: %v", delay, err)
+		})
+		if err != nil {
+			l.Errorf("vault login request error: %v", err)
+			return
+		}
+
+		var tokenTtl time.Duration
+		if tokenTtl, err = resp.TokenTTL(); err != nil {
+			l.Errorf("vault token ttl error: %v", err)
+			return
+		}
+
+		if lt.requestedTtl > 0 && tokenTtl < lt.requestedTtl && tokenTtl > 0 {
+			l.Warnf("vault client: requested token ttl %v was capped to %v by the role", lt.requestedTtl, tokenTtl)
+		}
+
+		lt.token = resp
+		lt.expiration = tokenExpiration(now, tokenTtl)
+	}
+
+	token = lt.token
+	return
+}
+
+// expiresAt provides the token's expiration as of its login or last refresh
+func (lt *loginToken) expiresAt() time.Time {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	return lt.expiration
+}
+
+// renewed records a renewal made outside of refresh, e.g., by Vault's
+// LifetimeWatcher, which extended the token to ttl from now
+func (lt *loginToken) renewed(ttl time.Duration) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.expiration = tokenExpiration(lt.clock.Now(), ttl)
+}
+
+// isExpired looks at the current time and indicates if the token has expired. A nil
+// token is considered expired.
+func (lt *loginToken) isExpired(l lane.Lane) (expired bool, err error) {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	if lt.token == nil {
+		expired = true
+	} else {
+		expired = lt.clock.Now().After(lt.expiration)
+	}
+	return
+}
+
+// isRevoked asks Vault to look up the token, and if the lookup fails, the token is
+// considered revoked. A nil token is also considered revoked. Failing to
+// prepare the lookup isn't a revocation: it is reported as ErrClientClone.
+func (lt *loginToken) isRevoked(l lane.Lane) (revoked bool, err error) {
+	// mu isn't held across the lookup, so it can't stall other callers
+	lt.mu.Lock()
+	if lt.token == nil {
+		lt.mu.Unlock()
+		revoked = true
+		return
+	}
+	client, err := lt.tokenClient()
+	lt.mu.Unlock()
+	if err != nil {
+		l.Errorf("can't check vault token revocation: %v", err)
+		return
+	}
+
+	_, testErr := lt.transport.LookupSelf(l, client)
+	revoked = (testErr != nil)
+	return
+}
+
+// refresh asks Vault to extend the life of the token, and suggests a number of
+// seconds to add via nextTtlInSeconds. Vault doesn't have to use the suggested
+// new TTL.
+func (lt *loginToken) refresh(l lane.Lane, nextTtlInSeconds int) (err error) {
+	// mu isn't held across the renewal, so it can't stall expiry checks
+	lt.mu.Lock()
+	token := lt.token
+	if token == nil {
+		lt.mu.Unlock()
+		err = fmt.Errorf("%w: can't refresh nil token", ErrNoToken)
+		return
+	}
+
+	// a token without a TTL has nothing to renew
+	if lt.expiration.Equal(neverExpires) {
+		lt.mu.Unlock()
+		return
+	}
+
+	if lt.clock.Now().After(lt.expiration) {
+		err = fmt.Errorf("%w: can't refresh a token that expired at %s", ErrTokenExpired, lt.expiration.Format(time.RFC3339))
+		lt.mu.Unlock()
+		return
+	}
+
+	client, err := lt.tokenClient()
+	lt.mu.Unlock()
+	if err != nil {
+		l.Errorf("can't refresh vault api token: %v", err)
+		return
+	}
+
+	var renewal *vaultapi.Secret
+	if renewal, err = lt.transport.RenewSelf(l, client, nextTtlInSeconds); err != nil {
+		if isInvalidToken(err) {
+			err = fmt.Errorf("%w: %w", ErrTokenRevoked, err)
+		}
+		l.Errorf("can't refresh vault api token: %v", err)
+		return
+	}
+
+	var tokenTtl time.Duration
+	if tokenTtl, err = renewal.TokenTTL(); err != nil {
+		l.Errorf("vault token refresh ttl error: %v", err)
+		return
+	}
+
+	// the renewal applies only to the token it was made for
+	lt.mu.Lock()
+	if lt.token == token {
+		lt.expiration = tokenExpiration(lt.clock.Now(), tokenTtl)
+	}
+	lt.mu.Unlock()
+	return
+}
+
+// revoke asks Vault to discontinue use of the current token. A new login is required
+// upon success.
+func (lt *loginToken) revoke(l lane.Lane) (err error) {
+	// mu isn't held across the revocation, so it can't stall expiry checks
+	lt.mu.Lock()
+	token := lt.token
+	if token == nil {
+		lt.mu.Unlock()
+		return
+	}
+	client, err := lt.tokenClient()
+	lt.mu.Unlock()
+	if err != nil {
+		l.Errorf("revoke vault token error: %v", err)
+		return
+	}
+
+	if err = lt.transport.RevokeSelf(l, client); err != nil {
+		l.Errorf("revoke vault token error: %v", err)
+		return
+	}
+
+	lt.mu.Lock()
+	if lt.token == token {
+		lt.token = nil
+	}
+	lt.mu.Unlock()
+	return
+}
+
+// tokenClient provides a client bearing this provider's token, since the
+// connection's client may have moved on to a newer login; lt.mu must be held
+func (lt *loginToken) tokenClient() (client *vaultapi.Client, err error) {
+	if client, err = lt.client.CloneWithHeaders(); err != nil {
+		err = fmt.Errorf("%w: %w", ErrClientClone, err)
+		return
+	}
+	client.SetToken(lt.token.Auth.ClientToken)
+	return
+}
@@ -0,0 +1,292 @@
+package vaulttoken
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jimsnab/go-lane"
+)
+
+func TestLoginTokenConcurrentUse(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// exercise the provider's state from many goroutines; -race reports any
+	// unguarded access
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Errorf("get api interface failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := vcc.IsTokenExpired(l); err != nil {
+				t.Errorf("expiry check failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := vcc.RefreshToken(l, 3600); err != nil {
+				t.Errorf("refresh failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := vcc.IsTokenRevoked(l); err != nil {
+				t.Errorf("revocation check failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the shared client carries the token of the recorded login
+	vcc.loginMu.Lock()
+	lastToken := vcc.lastLogin.Auth.ClientToken
+	vcc.loginMu.Unlock()
+	if got := vcc.vc.Token(); got != lastToken {
+		t.Errorf("client token %s doesn't match the last login %s", got, lastToken)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestLoginTokenRevokeThenLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	first := vc.Token()
+
+	if err = vcc.RevokeToken(l); err != nil {
+		t.Fatalf("revoke failed: %v", err)
+	}
+	if !mv.isRevoked(first) {
+		t.Errorf("token %s wasn't revoked", first)
+	}
+
+	if vc, err = vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("second login failed: %v", err)
+	}
+	if vc.Token() == first {
+		t.Errorf("the revoked token was reused")
+	}
+}
+
+func TestLoginTokenRenewalDoesNotBlockExpiryChecks(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// hold a renewal in flight
+	mv.mu.Lock()
+	mv.renewGate = make(chan struct{})
+	mv.mu.Unlock()
+	refreshed := make(chan error, 1)
+	go func() {
+		refreshed <- vcc.RefreshToken(l, 3600)
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for mv.renewals.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the renewal wasn't sent")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		if expired, err := vcc.IsTokenExpired(l); err != nil || expired {
+			t.Errorf("expected a live token, got expired=%t err=%v", expired, err)
+		}
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Errorf("get api interface failed: %v", err)
+		}
+	}()
+	select {
+	case <-checked:
+	case <-time.After(time.Second):
+		t.Error("expiry check blocked behind the renewal")
+	}
+
+	close(mv.renewGate)
+	if err := <-refreshed; err != nil {
+		t.Errorf("refresh failed: %v", err)
+	}
+	<-checked
+}
+
+func TestLoginRetriesServerErrors(t *testing.T) {
+	// leave the retrying to the login loop rather than vaultapi
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.loginErrors = []int{503, 503}
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if vc.Token() != "tok-1" {
+		t.Errorf("unexpected token %s", vc.Token())
+	}
+	if n := mv.failedLogins.Load(); n != 2 {
+		t.Errorf("expected 2 failed attempts, got %d", n)
+	}
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+	if n := vcc.LoginCount(); n != 1 {
+		t.Errorf("expected a login count of 1, got %d", n)
+	}
+}
+
+func TestLoginDoesNotRetryClientErrors(t *testing.T) {
+	t.Setenv("VAULT_MAX_RETRIES", "0")
+
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.loginErrors = []int{400}
+	vcc := newAppRoleClient(t, l, mv)
+
+	// a 400 won't succeed by repeating it
+	if _, err := vcc.GetApiInterface(l); err == nil {
+		t.Fatal("expected the login to fail")
+	}
+	if n := mv.failedLogins.Load(); n != 1 {
+		t.Errorf("expected 1 attempt, got %d", n)
+	}
+	if n := mv.logins.Load(); n != 0 {
+		t.Errorf("expected no login, got %d", n)
+	}
+}
+
+func TestLoginTokenZeroTTLNeverExpires(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.ttl = 0
+	fc := newFakeClock()
+	vcc := newAppRoleClient(t, l, mv, withClock(fc))
+
+	if _, err := vcc.GetApiInterface(l); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+
+	// years later, the token is still in use, and refreshing it is a no-op
+	for i := 0; i < 5; i++ {
+		fc.advance(fc.Now().Add(365 * 24 * time.Hour))
+		if _, err := vcc.GetApiInterface(l); err != nil {
+			t.Fatalf("get api interface failed: %v", err)
+		}
+		if err := vcc.RefreshToken(l, 3600); err != nil {
+			t.Fatalf("refresh failed: %v", err)
+		}
+	}
+
+	if n := mv.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+	if n := mv.renewals.Load(); n != 0 {
+		t.Errorf("expected no renewals, got %d", n)
+	}
+}
+
+func TestLoginTokenIsRevokedCloneFailure(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	token := vc.Token()
+
+	// a clone makes a new vaultapi client, which refuses malformed
+	// environment headers
+	t.Setenv("VAULT_HEADERS", "not json")
+
+	revoked, err := vcc.IsTokenRevoked(l)
+	if !errors.Is(err, ErrClientClone) {
+		t.Errorf("expected ErrClientClone, got %v", err)
+	}
+	if revoked {
+		t.Error("a failed clone mustn't report the token revoked")
+	}
+	if vc.Token() != token {
+		t.Errorf("the client's token changed to %q", vc.Token())
+	}
+}
+
+func TestStaticTokenIsRevokedCloneFailure(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	vcc := newStaticClient(t, l, mv)
+
+	t.Setenv("VAULT_HEADERS", "not json")
+
+	revoked, err := vcc.IsTokenRevoked(l)
+	if !errors.Is(err, ErrClientClone) {
+		t.Errorf("expected ErrClientClone, got %v", err)
+	}
+	if revoked {
+		t.Error("a failed clone mustn't report the token revoked")
+	}
+}
+
+func TestLoginRequestedTTL(t *testing.T) {
+	cases := []struct {
+		name      string
+		opts      []VaultOption
+		serverTtl int
+		bodyTtl   any
+		capped    bool
+	}{
+		{"role default", nil, 3600, nil, false},
+		{"shorter than role", []VaultOption{WithRequestedTTL(10 * time.Minute)}, 600, "600s", false},
+		{"capped by role", []VaultOption{WithRequestedTTL(2 * time.Hour)}, 3600, "7200s", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			l := lane.NewTestingLane(context.Background())
+			l.WantDescendantEvents(true)
+			mv := newMockVault(t)
+			mv.ttl = c.serverTtl
+			vcc := newAppRoleClient(t, l, mv, c.opts...)
+
+			if _, err := vcc.GetApiInterface(l); err != nil {
+				t.Fatalf("login failed: %v", err)
+			}
+			if ttl := mv.lastLoginBody()["ttl"]; ttl != c.bodyTtl {
+				t.Errorf("expected ttl %v in the login body, got %v", c.bodyTtl, ttl)
+			}
+			if capped := strings.Contains(l.EventsToString(), "was capped"); capped != c.capped {
+				t.Errorf("expected capped warning %v, got %v:\n%s", c.capped, capped, l.EventsToString())
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package vaulttoken
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+type (
+	// VaultTransport carries the login and token calls this package makes
+	// to Vault. The default sends them over HTTP with the vaultapi client;
+	// an alternative (e.g., a gRPC gateway) can be supplied with
+	// WithVaultTransport. When a login or renewal is retried, an error with a
+	// RetryAfter() time.Duration method sets the wait before the next try;
+	// the default transport's errors have it when Vault sent Retry-After.
+	VaultTransport interface {
+		Login(l lane.Lane, client *vaultapi.Client, loginPath string, data map[string]any) (*vaultapi.Secret, error)
+		LookupSelf(l lane.Lane, client *vaultapi.Client) (*vaultapi.Secret, error)
+		RenewSelf(l lane.Lane, client *vaultapi.Client, increment int) (*vaultapi.Secret, error)
+		RevokeSelf(l lane.Lane, client *vaultapi.Client) error
+	}
+
+	httpVaultTransport struct {
+	}
+)
+
+// WithVaultTransport replaces the HTTP transport of the login and token calls.
+func WithVaultTransport(transport VaultTransport) VaultOption {
+	return func(opts *vaultOptions) {
+		opts.transport = transport
+	}
+}
+
+func (t httpVaultTransport) Login(l lane.Lane, client *vaultapi.Client, loginPath string, data map[string]any) (*vaultapi.Secret, error) {
+	return writeKeepingRetryAfter(l, client, loginPath, data)
+}
+
+func (t httpVaultTransport) LookupSelf(l lane.Lane, client *vaultapi.Client) (*vaultapi.Secret, error) {
+	return client.Auth().Token().LookupSelfWithContext(l)
+}
+
+func (t httpVaultTransport) RenewSelf(l lane.Lane, client *vaultapi.Client, increment int) (*vaultapi.Secret, error) {
+	return writeKeepingRetryAfter(l, client, "auth/token/renew-self", map[string]any{"increment": increment})
+}
+
+func (t httpVaultTransport) RevokeSelf(l lane.Lane, client *vaultapi.Client) error {
+	return client.Auth().Token().RevokeSelfWithContext(l, "")
+}
+
+// writeKeepingRetryAfter writes data to path like Logical().Write, except
+// that the Retry-After header of a failed response is kept on the error,
+// which vaultapi otherwise discards
+func writeKeepingRetryAfter(l lane.Lane, client *vaultapi.Client, path string, data map[string]any) (secret *vaultapi.Secret, err error) {
+	// the client timeout must cover reading the body too; vaultapi's raw
+	// writes end their timeout context as soon as the response arrives
+	if timeout := client.ClientTimeout(); timeout > 0 {
+		var cancel context.CancelFunc
+		l, cancel = l.DeriveWithTimeout(timeout)
+		defer cancel()
+	}
+
+	r := client.NewRequest(http.MethodPut, "/v1/"+path)
+	if err = r.SetJSONBody(data); err != nil {
+		return
+	}
+
+	var resp *vaultapi.Response
+	resp, err = client.RawRequestWithContext(l, r) //nolint:staticcheck // the raw response is needed for its headers
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		if resp != nil {
+			if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+				err = &retryAfterResponseError{err: err, retryAfter: delay}
+			}
+		}
+		return
+	}
+
+	return vaultapi.ParseSecret(resp.Body)
+}
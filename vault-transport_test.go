@@ -0,0 +1,183 @@
+package vaulttoken
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/jimsnab/go-lane"
+)
+
+// newMockApiClient makes a plain vault api client for the mock server
+func newMockApiClient(t testing.TB, mv *mockVault) *vaultapi.Client {
+	cfg := vaultapi.DefaultConfig()
+	cfg.Address = mv.srv.URL
+	cfg.MaxRetries = 0
+	client, err := vaultapi.NewClient(cfg)
+	if err != nil {
+		t.Fatalf("can't make vault api client: %v", err)
+	}
+	return client
+}
+
+func TestHttpTransportSlowBody(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.slowBodies = true
+	client := newMockApiClient(t, mv)
+
+	// the body arrives after the response headers, and must still be read
+	// within the request's timeout
+	secret, err := httpVaultTransport{}.Login(l, client, "auth/approle/login", map[string]any{"role_id": "r"})
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if secret.Auth == nil || secret.Auth.ClientToken != "tok-1" {
+		t.Fatalf("unexpected login response %+v", secret)
+	}
+
+	client.SetToken(secret.Auth.ClientToken)
+	if secret, err = (httpVaultTransport{}).RenewSelf(l, client, 600); err != nil {
+		t.Fatalf("renewal failed: %v", err)
+	}
+	if ttl, _ := secret.TokenTTL(); ttl != time.Hour {
+		t.Errorf("expected a 1h ttl, got %v", ttl)
+	}
+}
+
+func TestHttpTransportSlowBodyLogin(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.slowBodies = true
+	vcc := newAppRoleClient(t, l, mv)
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if vc.Token() != "tok-1" {
+		t.Errorf("unexpected token %s", vc.Token())
+	}
+	if err = vcc.RefreshToken(l, 600); err != nil {
+		t.Errorf("refresh failed: %v", err)
+	}
+}
+
+func TestHttpTransportRetryAfter(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	mv := newMockVault(t)
+	mv.loginErrors = []int{503}
+	mv.retryAfter = "7"
+	client := newMockApiClient(t, mv)
+
+	_, err := httpVaultTransport{}.Login(l, client, "auth/approle/login", map[string]any{"role_id": "r"})
+	ra, hasRetryAfter := err.(retryAfterError)
+	if !hasRetryAfter {
+		t.Fatalf("expected an error with the Retry-After delay, got %v", err)
+	}
+	if ra.RetryAfter() != 7*time.Second {
+		t.Errorf("expected 7s, got %v", ra.RetryAfter())
+	}
+}
+
+type (
+	// fakeTransport is a VaultTransport that answers without a server
+	fakeTransport struct {
+		mu        sync.Mutex
+		calls     []string
+		revoked   map[string]bool
+		loginPath string
+		loginBody map[string]any
+	}
+)
+
+func (ft *fakeTransport) record(call string) {
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	ft.calls = append(ft.calls, call)
+}
+
+func (ft *fakeTransport) Login(l lane.Lane, client *vaultapi.Client, loginPath string, data map[string]any) (*vaultapi.Secret, error) {
+	ft.record("login")
+	ft.mu.Lock()
+	ft.loginPath = loginPath
+	ft.loginBody = data
+	ft.mu.Unlock()
+	return &vaultapi.Secret{Auth: &vaultapi.SecretAuth{ClientToken: "fake-token", LeaseDuration: 600, Renewable: true}}, nil
+}
+
+func (ft *fakeTransport) LookupSelf(l lane.Lane, client *vaultapi.Client) (*vaultapi.Secret, error) {
+	ft.record("lookup")
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	if ft.revoked[client.Token()] {
+		return nil, errors.New("permission denied")
+	}
+	return &vaultapi.Secret{Data: map[string]any{"id": client.Token(), "ttl": json.Number("600"), "explicit_max_ttl": json.Number("0")}}, nil
+}
+
+func (ft *fakeTransport) RenewSelf(l lane.Lane, client *vaultapi.Client, increment int) (*vaultapi.Secret, error) {
+	ft.record("renew")
+	return &vaultapi.Secret{Auth: &vaultapi.SecretAuth{ClientToken: client.Token(), LeaseDuration: increment, Renewable: true}}, nil
+}
+
+func (ft *fakeTransport) RevokeSelf(l lane.Lane, client *vaultapi.Client) error {
+	ft.record("revoke")
+	ft.mu.Lock()
+	defer ft.mu.Unlock()
+	ft.revoked[client.Token()] = true
+	return nil
+}
+
+func TestWithVaultTransport(t *testing.T) {
+	l := lane.NewTestingLane(context.Background())
+	ft := &fakeTransport{revoked: map[string]bool{}}
+
+	// nothing listens at the address, so every call must use the transport
+	vcc, err := NewVaultClientAppRole(l, "http://127.0.0.1:1", "", "", "role-id", "secret-id", WithVaultTransport(ft))
+	if err != nil {
+		t.Fatalf("can't make approle client: %v", err)
+	}
+
+	vc, err := vcc.GetApiInterface(l)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if vc.Token() != "fake-token" {
+		t.Errorf("unexpected token %s", vc.Token())
+	}
+	if ft.loginPath != "auth/approle/login" || ft.loginBody["role_id"] != "role-id" {
+		t.Errorf("unexpected login request %s %v", ft.loginPath, ft.loginBody)
+	}
+
+	if revoked, err := vcc.IsTokenRevoked(l); err != nil || revoked {
+		t.Errorf("expected a live token, got revoked=%t err=%v", revoked, err)
+	}
+	if err = vcc.RefreshToken(l, 1200); err != nil {
+		t.Errorf("refresh failed: %v", err)
+	}
+	provider, _ := vcc.liveProvider()
+	if remaining := time.Until(provider.expiresAt()); remaining < 19*time.Minute {
+		t.Errorf("the renewal wasn't applied, %v remaining", remaining)
+	}
+	if err = vcc.RevokeToken(l); err != nil {
+		t.Errorf("revoke failed: %v", err)
+	}
+	if !ft.revoked["fake-token"] {
+		t.Error("the token wasn't revoked through the transport")
+	}
+
+	ft.mu.Lock()
+	calls := strings.Join(ft.calls, ",")
+	ft.mu.Unlock()
+	for _, call := range []string{"login", "lookup", "renew", "revoke"} {
+		if !strings.Contains(calls, call) {
+			t.Errorf("no %s call, got %s", call, calls)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+// Package vaulttest provides test helpers for applications using vaulttoken.
+package vaulttest
+
+import (
+	"strings"
+	"testing"
+)
+
+// AssertNoSecretsLogged fails the test if any of the secrets appear in the
+// captured lane output (for example, the text collected by a lane test
+// harness). Empty secrets are ignored. The failure message identifies the
+// secret by a masked form so the test log doesn't repeat the leak.
+func AssertNoSecretsLogged(t testing.TB, laneOutput string, secrets ...string) {
+	t.Helper()
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		if strings.Contains(laneOutput, secret) {
+			t.Errorf("secret %s was found in the lane output", maskSecret(secret))
+		}
+	}
+}
+
+// maskSecret reveals only enough of a secret to tell which one leaked
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", len(secret)-4)
+}
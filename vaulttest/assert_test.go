@@ -0,0 +1,70 @@
+package vaulttest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type (
+	// fakeTB records failures instead of failing the running test
+	fakeTB struct {
+		testing.TB
+		errors []string
+	}
+)
+
+func (f *fakeTB) Helper() {
+}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertNoSecretsLoggedDetectsLeak(t *testing.T) {
+	ft := &fakeTB{}
+	output := "INFO\tlogged in\nDEBUG\tusing secret s3cr3t-value-1234\n"
+	AssertNoSecretsLogged(ft, output, "other-secret", "s3cr3t-value-1234")
+
+	if len(ft.errors) != 1 {
+		t.Fatalf("expected 1 failure, got %d: %v", len(ft.errors), ft.errors)
+	}
+	// the failure message doesn't repeat the leak
+	if strings.Contains(ft.errors[0], "s3cr3t-value-1234") {
+		t.Errorf("failure message contains the secret: %s", ft.errors[0])
+	}
+	if !strings.Contains(ft.errors[0], "s3cr*************") {
+		t.Errorf("failure message doesn't identify the secret: %s", ft.errors[0])
+	}
+}
+
+func TestAssertNoSecretsLoggedClean(t *testing.T) {
+	ft := &fakeTB{}
+	AssertNoSecretsLogged(ft, "INFO\tlogged in\n", "s3cr3t-value-1234")
+	if len(ft.errors) != 0 {
+		t.Errorf("unexpected failures: %v", ft.errors)
+	}
+}
+
+func TestAssertNoSecretsLoggedIgnoresEmpty(t *testing.T) {
+	ft := &fakeTB{}
+	AssertNoSecretsLogged(ft, "INFO\tlogged in\n", "", "")
+	if len(ft.errors) != 0 {
+		t.Errorf("empty secrets shouldn't match: %v", ft.errors)
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	cases := map[string]string{
+		"":             "",
+		"short":        "*****",
+		"12345678":     "********",
+		"123456789":    "1234*****",
+		"hvs.abcdefgh": "hvs.********",
+	}
+	for secret, expected := range cases {
+		if masked := maskSecret(secret); masked != expected {
+			t.Errorf("maskSecret(%q) = %q, expected %q", secret, masked, expected)
+		}
+	}
+}